@@ -1,9 +1,12 @@
 package badger
 
 import (
+	"time"
+
 	"github.com/dgraph-io/badger/v4"
 )
 
+// Key-value store next to the eventstore, in the same badger database.
 type Cache struct {
 	*badger.DB
 }
@@ -15,7 +18,8 @@ func New(db *badger.DB) (*Cache, error) {
 	}, nil
 }
 
-func (c *Cache) Get(key string) ([]byte, bool) {
+// The value of the key, false if it does not exist or expired.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
 	var val []byte
 	err := c.View(func(txn *badger.Txn) error {
 		b, err := txn.Get([]byte(key))
@@ -28,22 +32,25 @@ func (c *Cache) Get(key string) ([]byte, bool) {
 	})
 
 	if err == badger.ErrKeyNotFound {
-		return nil, false
+		return nil, false, nil
 	}
 	if err != nil {
-		panic(err)
+		return nil, false, err
 	}
 
-	return val, true
+	return val, true, nil
 }
 
-func (c *Cache) Set(key string, value []byte) {
-	err := c.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(key), value)
+// Set the value of the key. The key expires after the ttl, using the native
+// expiry of badger, or never when the ttl is zero.
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
+	return c.Update(func(txn *badger.Txn) error {
+		e := badger.NewEntry([]byte(key), value)
+		if ttl > 0 {
+			e = e.WithTTL(ttl)
+		}
+		return txn.SetEntry(e)
 	})
-	if err != nil {
-		panic(err)
-	}
 }
 
 func (c *Cache) Delete(key string) error {
@@ -51,3 +58,22 @@ func (c *Cache) Delete(key string) error {
 		return txn.Delete([]byte(key))
 	})
 }
+
+// All keys that start with the prefix.
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	err := c.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(prefix)
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().KeyCopy(nil)))
+		}
+		return nil
+	})
+	return keys, err
+}
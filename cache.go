@@ -0,0 +1,162 @@
+package notezero
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip05"
+)
+
+// Namespaces of the cache keys.
+const (
+	// highlights:<kind>:<pubkey>:<d>, last sync of the highlights of an article
+	nsHighlights = "highlights"
+	// backlinks:<kind>:<pubkey>:<d>, last sync of the references to an article
+	nsBacklinks = "backlinks"
+	// tags:<tag>, last sync of the articles with a hashtag
+	nsTags = "tags"
+	// refs:<kind>:<pubkey>:<d>:<id>, index of the events that refer to an article
+	nsReferences = "refs"
+	// expiration:<timestamp>:<id>, index of the events that expire
+	nsExpiration = "expiration"
+	// nip05:<identifier>, resolved NIP-05 identifiers
+	nsNip05 = "nip05"
+	// access:<id>, last time a stored event was read, for the retention policy
+	nsAccess = "access"
+	// mirror:<pubkey>:<stream>, created_at of the newest event mirrored
+	nsMirror = "mirror"
+	// deletions:<id or pubkey>, last check for deletions of an event, or of
+	// the articles of an author
+	nsDeletions = "deletions"
+	// lists:<hash>, last sync of a filter for a list, like the articles of an author
+	nsLists = "lists"
+	// retention:backfill, when the events stored without an access record
+	// were given one
+	nsRetention = "retention"
+)
+
+const (
+	// Afterwards the relays are waited on again, instead of refreshing in the
+	// background, so a page does not lag behind forever.
+	syncTTL = 7 * 24 * time.Hour
+	// NIP-05 records can change at any time, but rarely do.
+	nip05TTL = time.Hour
+	// Pages served from the store are refreshed from the relays at most this
+	// often.
+	refreshInterval = 15 * time.Minute
+	// Longer than a request to the relays waits, since nobody waits on it.
+	refreshTimeout = 10 * time.Second
+)
+
+// Key in a namespace, like highlights:<kind>:<pubkey>:<d>. Namespaces keep
+// the keys of different features from colliding.
+//
+// Parts are escaped, so a part with ":", like a d tag, cannot make the key of
+// one article a prefix of another.
+func cacheKey(namespace string, parts ...string) string {
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		escaped[i] = keyEscaper.Replace(part)
+	}
+	return namespace + ":" + strings.Join(escaped, ":")
+}
+
+var keyEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// Kind, pubkey and d tag of an address, as parts of a cache key.
+func addressParts(address string) []string {
+	return strings.SplitN(address, ":", 3)
+}
+
+// Decode the JSON value of the key into v.
+func getJSON(c Cache, key string, v any) (bool, error) {
+	val, found, err := c.Get(key)
+	if err != nil || !found {
+		return false, err
+	}
+	return true, json.Unmarshal(val, v)
+}
+
+func setJSON(c Cache, key string, v any, ttl time.Duration) error {
+	val, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Set(key, val, ttl)
+}
+
+// Time the key was last synced with the relays, false if it never was or
+// the sync expired.
+func (s eventService) lastSync(key string) (nostr.Timestamp, bool, error) {
+	var ts nostr.Timestamp
+	found, err := getJSON(s.cache, key, &ts)
+	return ts, found, err
+}
+
+// Whether the key was not synced within the refresh interval.
+func (s eventService) stale(key string) (bool, error) {
+	ts, found, err := s.lastSync(key)
+	if err != nil {
+		return false, err
+	}
+	return !found || time.Since(ts.Time()) >= refreshInterval, nil
+}
+
+// Refresh what the key stands for from the relays in the background, unless
+// it was synced within the refresh interval. Concurrent refreshes of the same
+// key share one.
+func (s eventService) refresh(key string, fn func(ctx context.Context)) {
+
+	if stale, err := s.stale(key); err != nil || !stale {
+		return
+	}
+
+	go s.refreshes.Do(key, func() (any, error) {
+		// Another refresh could have finished since the check above
+		if stale, err := s.stale(key); err != nil || !stale {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+		fn(ctx)
+		return nil, s.setLastSync(key)
+	})
+}
+
+func (s eventService) setLastSync(key string) error {
+	return setJSON(s.cache, key, nostr.Now(), syncTTL)
+}
+
+// Profile pointer of a NIP-05 identifier like alice@example.com, which is
+// only requested from the domain again once the cached record expires.
+func (s eventService) ResolveNip05(ctx context.Context, identifier string) (*nostr.ProfilePointer, error) {
+
+	key := cacheKey(nsNip05, nip05.NormalizeIdentifier(identifier))
+
+	var pp nostr.ProfilePointer
+	found, err := getJSON(s.cache, key, &pp)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return &pp, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	resolved, err := nip05.QueryIdentifier(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setJSON(s.cache, key, resolved, nip05TTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	nz "github.com/dextryz/notezero"
+
+	"github.com/dextryz/notezero/store"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: notezero <command> [arguments]
+
+commands:
+  lint <npub>       list the articles of an author that have an incorrect format
+  store export      write the events of the store as JSON lines
+  store import      load events from JSON lines, verified like events from relays
+  store backup      write a backup of a badger store, or of a running server
+  store restore     load a backup into a new badger store`)
+}
+
+func main() {
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch flag.Arg(0) {
+	case "lint":
+		err = lint(flag.Args()[1:])
+	case "store":
+		err = storeCommand(flag.Args()[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// The CLI does not share the server's nostr.db, since badger only allows a
+// single process to open it. Everything is kept in memory instead.
+func newService() (nz.EventService, error) {
+
+	st, err := store.Open(store.Memory, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return nz.NewEventService(st.Events, st.Cache, nz.DefaultRelays), nil
+}
+
+func lint(args []string) error {
+
+	if len(args) != 1 {
+		return fmt.Errorf("usage: notezero lint <npub>")
+	}
+
+	s, err := newService()
+	if err != nil {
+		return err
+	}
+
+	events, err := s.AuthorArticles(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	reports := nz.LintArticles(events)
+
+	for _, report := range reports {
+		fmt.Printf("%s (%s)\n", report.Name(), report.Event.Naddr())
+		for _, p := range report.Problems {
+			fmt.Printf("  %s: %s\n", p.Severity, p.Message)
+		}
+	}
+
+	fmt.Printf("%d of %d articles have problems\n", len(reports), len(events))
+
+	return nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	nz "github.com/dextryz/notezero"
+	"github.com/dextryz/notezero/store"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Largest line accepted by import, an event within the ingestion size limit
+// with plenty of room for its tags.
+const maxLineSize = 4 * 1024 * 1024
+
+func storeCommand(args []string) error {
+
+	if len(args) < 1 {
+		return fmt.Errorf("usage: notezero store export|import|backup|restore [flags]")
+	}
+
+	switch args[0] {
+	case "export":
+		return exportEvents(args[1:])
+	case "import":
+		return importEvents(args[1:])
+	case "backup":
+		return backup(args[1:])
+	case "restore":
+		return restore(args[1:])
+	}
+
+	return fmt.Errorf("unknown store command: %s", args[0])
+}
+
+// Flags to select the store, which default to the server configuration.
+func storeFlags(fs *flag.FlagSet) (backend, path *string) {
+	cfg := nz.ConfigFromEnv()
+	backend = fs.String("store", cfg.Store, "backend of the store: "+strings.Join(store.Backends(), ", "))
+	path = fs.String("path", cfg.StorePath, "path of the store, the default of the backend when empty")
+	return backend, path
+}
+
+// Write to the file, or to stdout when it is empty.
+func create(name string) (io.WriteCloser, error) {
+	if name == "" {
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
+
+// Read from the file, or from stdin when it is empty.
+func open(name string) (io.ReadCloser, error) {
+	if name == "" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+func exportEvents(args []string) error {
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	backend, path := storeFlags(fs)
+	output := fs.String("o", "", "file to write, stdout when empty")
+	kinds := fs.String("kinds", "", "comma separated kinds to export")
+	authors := fs.String("authors", "", "comma separated npubs or hex keys to export")
+	since := fs.Int64("since", 0, "only events created at or after this unix time")
+	until := fs.Int64("until", 0, "only events created at or before this unix time")
+	limit := fs.Int("limit", 0, "export at most this many events, the newest first")
+	fs.Parse(args)
+
+	filter := nostr.Filter{Limit: *limit}
+
+	for _, v := range splitList(*kinds) {
+		kind, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid kind: %s", v)
+		}
+		filter.Kinds = append(filter.Kinds, kind)
+	}
+
+	for _, v := range splitList(*authors) {
+		pk, err := pubkey(v)
+		if err != nil {
+			return err
+		}
+		filter.Authors = append(filter.Authors, pk)
+	}
+
+	if *since != 0 {
+		ts := nostr.Timestamp(*since)
+		filter.Since = &ts
+	}
+	if *until != 0 {
+		ts := nostr.Timestamp(*until)
+		filter.Until = &ts
+	}
+
+	st, err := store.Open(*backend, *path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	w, err := create(*output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	bw := bufio.NewWriter(w)
+
+	count, err := store.Export(context.Background(), st.Events, bw, filter)
+	if err != nil {
+		return err
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d events\n", count)
+
+	return nil
+}
+
+// Events are verified, and replaceable events reconciled, like the events the
+// server receives from relays. Only the newest version of each is kept.
+func importEvents(args []string) error {
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	backend, path := storeFlags(fs)
+	input := fs.String("i", "", "JSONL file to read, stdin when empty")
+	fs.Parse(args)
+
+	st, err := store.Open(*backend, *path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	r, err := open(*input)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// Nothing is requested from the relays
+	s := nz.NewEventService(st.Events, st.Cache, nil)
+
+	ctx := context.Background()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	read, rejected := 0, 0
+
+	for line := 1; scanner.Scan(); line++ {
+
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		read++
+
+		var e nostr.Event
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: invalid event: %v\n", line, err)
+			rejected++
+			continue
+		}
+
+		err = s.Import(ctx, &e)
+		if errors.Is(err, nz.ErrRejected) {
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+			rejected++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+
+	// Stale versions and deleted events pass the checks, but are not stored
+	fmt.Fprintf(os.Stderr, "imported %d events, %d rejected\n", read-rejected, rejected)
+
+	return nil
+}
+
+// A running server holds the lock on its store, so its backup is requested
+// over HTTP instead.
+func backup(args []string) error {
+
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	backend, path := storeFlags(fs)
+	output := fs.String("o", "", "file to write, stdout when empty")
+	server := fs.String("server", "", "URL of a running server to back up, with NZ_ADMIN_TOKEN")
+	fs.Parse(args)
+
+	w, err := create(*output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if *server != "" {
+		return download(strings.TrimSuffix(*server, "/")+"/admin/backup", w)
+	}
+
+	st, err := store.Open(*backend, *path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	return st.Backup(w)
+}
+
+func download(url string, w io.Writer) error {
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("NZ_ADMIN_TOKEN"))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("backup failed: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	_, err = io.Copy(w, res.Body)
+
+	return err
+}
+
+// The backup is loaded into a new store, which the server can open afterwards.
+func restore(args []string) error {
+
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	backend, path := storeFlags(fs)
+	input := fs.String("i", "", "backup file to read, stdin when empty")
+	fs.Parse(args)
+
+	r, err := open(*input)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return store.Restore(*backend, *path, bufio.NewReader(r))
+}
+
+func splitList(v string) []string {
+	list := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func pubkey(v string) (string, error) {
+	if prefix, data, err := nip19.Decode(v); err == nil && prefix == "npub" {
+		return data.(string), nil
+	}
+	if nostr.IsValidPublicKeyHex(v) {
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid author: %s", v)
+}
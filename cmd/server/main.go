@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	nz "github.com/dextryz/notezero"
 
-	"github.com/dextryz/notezero/badger"
-	eventstore_badger "github.com/fiatjaf/eventstore/badger"
+	"github.com/dextryz/notezero/store"
 )
 
 func main() {
@@ -19,43 +22,98 @@ func main() {
 
 	log.Info("Starting")
 
-	relays := []string{
-		"wss://relay.damus.io/",
-		"wss://nostr-01.yakihonne.com",
-		// "wss://nostr-02.yakihonne.com",
-		"wss://relay.highlighter.com/",
-		//"wss://relay.f7z.io",
-		"wss://nos.lol",
-	}
+	cfg := nz.ConfigFromEnv()
 
-	db := &eventstore_badger.BadgerBackend{
-		Path: "nostr.db",
-	}
-	err := db.Init()
+	st, err := store.Open(cfg.Store, cfg.StorePath)
 	if err != nil {
-		panic(err)
-	}
-
-	cache, err := badger.New(db.DB)
-	if err != nil {
-		log.Error("failed to create store", slog.Any("error", err))
+		log.Error("failed to open store", slog.Any("error", err))
 		os.Exit(1)
 	}
+	defer st.Close()
+
+	log.Info("opened store", "backend", st.Backend)
+
+	mem := nz.NewMemoryCache(cfg.MemoryCacheMB)
+
+	s := nz.NewEventService(st.Events, st.Cache, nz.DefaultRelays).
+		WithRepublish(cfg.RepublishStale).
+		WithMemoryCache(mem).
+		WithRetention(cfg.Retention())
+
+	// NIP-40, remove events once they expire
+	go func() {
+		for range time.Tick(time.Minute) {
+			count, err := s.SweepExpired(context.Background())
+			if err != nil {
+				log.Error("failed to sweep expired events", slog.Any("error", err))
+				continue
+			}
+			if count != 0 {
+				log.Info("swept expired events", "count", count)
+			}
+		}
+	}()
+
+	// Mirror the pinned authors into the store, so their pages never wait on
+	// the relays. Catch up on start and then every 15 minutes.
+	if len(cfg.PinnedAuthors) != 0 {
+		go s.MirrorLive(context.Background(), cfg.PinnedAuthors)
+		go func() {
+			for {
+				count, err := s.CatchUp(context.Background(), cfg.PinnedAuthors)
+				if err != nil {
+					log.Error("failed to catch up on pinned authors", slog.Any("error", err))
+				} else {
+					log.Info("caught up on pinned authors", "authors", len(cfg.PinnedAuthors), "events", count)
+				}
+				time.Sleep(15 * time.Minute)
+			}
+		}()
+	}
 
-	s := nz.NewEventService(db, cache, relays)
-	l := nz.NewLogging(log, s)
-	h := nz.NewHandler(log, l)
+	// Evict events by the retention policy, then give their space back
+	go func() {
+		for range time.Tick(time.Hour) {
+			report, err := s.Collect(context.Background())
+			if err != nil {
+				log.Error("failed to evict events", slog.Any("error", err))
+				continue
+			}
+			reclaimed, err := st.Compact()
+			if err != nil {
+				log.Error("failed to compact store", slog.Any("error", err))
+				continue
+			}
+			log.Info("collected store", "evicted", report.Evicted, "evictedBytes", report.Bytes, "reclaimedBytes", reclaimed)
+		}
+	}()
+
+	// Concurrent requests for the same article share one relay fan-out
+	l := nz.NewLogging(log, nz.NewCoalescing(s))
+	h := nz.NewHandler(log, l, cfg).WithMemoryCache(mem)
 
 	mux := http.NewServeMux()
 
 	fs := http.FileServer(http.Dir("./static"))
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
+	// The counters name the relays and hosts the instance talks to, so they
+	// are not public
+	if cfg.AdminToken != "" {
+		mux.Handle("GET /debug/vars", requireToken(cfg.AdminToken, expvar.Handler()))
+		mux.Handle("GET /admin/backup", requireToken(cfg.AdminToken, backupHandler(log, st)))
+	}
+
 	mux.HandleFunc("/", h.Homepage)
 	mux.HandleFunc("GET /search", h.RedirectSearch)
+	mux.HandleFunc("GET /tags/{tag}", h.TagHandler)
 	mux.HandleFunc("GET /nz/{code}", h.CodeHandler)
+	mux.HandleFunc("GET /nz/{npub}/lint", h.LintHandler)
+	mux.HandleFunc("GET /nz/{npub}/graph.json", h.GraphHandler)
+	mux.HandleFunc("GET /nz/{npub}/graph.graphml", h.GraphMLHandler)
 	mux.HandleFunc("GET /nz/{npub}/{naddr}", h.ArticleHandler)
-	mux.HandleFunc("GET /nz/content/{naddr}", h.ContentHandler)
+	mux.HandleFunc("GET /nz/{npub}/{naddr}/content", h.ContentHandler)
+	mux.HandleFunc("GET /nz/{npub}/{naddr}/backlinks", h.BacklinksHandler)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -73,3 +131,41 @@ func main() {
 
 	server.ListenAndServe()
 }
+
+// Only serve requests with the admin token as bearer token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stream a backup of the store while the server keeps running.
+func backupHandler(log *slog.Logger, st *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// The backup takes as long as the store is large, well beyond the
+		// write timeout of the server, which would cut it off silently
+		err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		if err != nil {
+			log.Error("failed to clear write deadline", slog.Any("error", err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "notezero-"+time.Now().UTC().Format("20060102-150405")+".bak"))
+
+		err = st.Backup(w)
+		if err != nil {
+			// Nothing was written if the backend does not support backups
+			log.Error("failed to back up store", slog.Any("error", err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
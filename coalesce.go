@@ -0,0 +1,131 @@
+package notezero
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"golang.org/x/sync/singleflight"
+)
+
+// Lookups served by a call that was already in flight, served on /debug/vars.
+var coalescedStats = expvar.NewMap("coalesced_requests")
+
+// Shares the result of identical lookups that are in flight at the same time,
+// so a popular article only fans out to the relays once.
+type coalescing struct {
+	group *singleflight.Group
+	next  EventService
+}
+
+func NewCoalescing(next EventService) coalescing {
+	return coalescing{
+		group: &singleflight.Group{},
+		next:  next,
+	}
+}
+
+//  1. Join the call in flight for the key, or start one
+//  2. The call is detached from the context of the caller that started it, so
+//     cancelling one request does not fail the others waiting on it
+//  3. Each caller stops waiting when its own context is done
+func (s coalescing) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+
+	ch := s.group.DoChan(key, func() (any, error) {
+		return fn(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Shared {
+			coalescedStats.Add(strings.SplitN(key, ":", 2)[0], 1)
+		}
+		return res.Val, res.Err
+	}
+}
+
+func (s coalescing) events(ctx context.Context, key string, fn func(ctx context.Context) ([]*nostr.Event, error)) ([]*nostr.Event, error) {
+
+	v, err := s.do(ctx, key, func(ctx context.Context) (any, error) {
+		return fn(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers get their own slice, so appending or sorting does not leak
+	return slices.Clone(v.([]*nostr.Event)), nil
+}
+
+// Codes that RequestEvent resolves the same way share the key, like naddrs of
+// an article with different relay hints. Other codes are keyed on the code
+// itself, so an unsupported code, like an nprofile, does not share the error
+// with the npub of the same author.
+func codeKey(code string) string {
+
+	prefix, data, err := nip19.Decode(code)
+	if err != nil {
+		return code
+	}
+
+	switch v := data.(type) {
+	case string:
+		if prefix == "npub" {
+			return "0:" + v
+		}
+	case nostr.EntityPointer:
+		return fmt.Sprintf("%d:%s:%s", v.Kind, v.PublicKey, v.Identifier)
+	}
+
+	return code
+}
+
+func (s coalescing) RequestEvent(ctx context.Context, code string) (*nostr.Event, error) {
+
+	v, err := s.do(ctx, "event:"+codeKey(code), func(ctx context.Context) (any, error) {
+		return s.next.RequestEvent(ctx, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*nostr.Event), nil
+}
+
+func (s coalescing) AuthorArticles(ctx context.Context, npub string) ([]*nostr.Event, error) {
+	return s.events(ctx, "articles:"+codeKey(npub), func(ctx context.Context) ([]*nostr.Event, error) {
+		return s.next.AuthorArticles(ctx, npub)
+	})
+}
+
+func (s coalescing) TagArticles(ctx context.Context, tag string) ([]*nostr.Event, error) {
+	return s.events(ctx, "tag:"+strings.ToLower(tag), func(ctx context.Context) ([]*nostr.Event, error) {
+		return s.next.TagArticles(ctx, tag)
+	})
+}
+
+func (s coalescing) ArticleHighlights(ctx context.Context, kind int, pubkey, identifier string) ([]*nostr.Event, error) {
+	key := fmt.Sprintf("highlights:%d:%s:%s", kind, pubkey, identifier)
+	return s.events(ctx, key, func(ctx context.Context) ([]*nostr.Event, error) {
+		return s.next.ArticleHighlights(ctx, kind, pubkey, identifier)
+	})
+}
+
+func (s coalescing) ArticleBacklinks(ctx context.Context, kind int, pubkey, identifier string) ([]*nostr.Event, error) {
+	key := fmt.Sprintf("backlinks:%d:%s:%s", kind, pubkey, identifier)
+	return s.events(ctx, key, func(ctx context.Context) ([]*nostr.Event, error) {
+		return s.next.ArticleBacklinks(ctx, kind, pubkey, identifier)
+	})
+}
+
+func (s coalescing) FetchEvents(ctx context.Context, filters nostr.Filters) ([]*nostr.Event, error) {
+	return s.events(ctx, "fetch:"+filters.String(), func(ctx context.Context) ([]*nostr.Event, error) {
+		return s.next.FetchEvents(ctx, filters)
+	})
+}
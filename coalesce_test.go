@@ -0,0 +1,118 @@
+package notezero
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Serves the events by code and holds every call until released, so the
+// lookups of a test overlap.
+type heldService struct {
+	EventService
+	events  map[string]*nostr.Event
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *heldService) RequestEvent(ctx context.Context, code string) (*nostr.Event, error) {
+	s.calls.Add(1)
+	<-s.release
+	if e, ok := s.events[code]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("code type not supported: %s", code)
+}
+
+func TestCodeKey(t *testing.T) {
+
+	pk := "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"
+	id := "f7234bd4c1394dda46d09f35bd384dd30cc552ad5541990f98844fb06676e9ca"
+
+	npub, _ := nip19.EncodePublicKey(pk)
+	nprofile, _ := nip19.EncodeProfile(pk, nil)
+	note, _ := nip19.EncodeNote(id)
+	nevent, _ := nip19.EncodeEvent(id, nil, "")
+	naddr, _ := nip19.EncodeEntity(pk, nostr.KindArticle, "intro", nil)
+	hinted, _ := nip19.EncodeEntity(pk, nostr.KindArticle, "intro", []string{"wss://relay.example.com"})
+
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"naddr with relay hints", naddr, hinted, true},
+		{"npub and nprofile", npub, nprofile, false},
+		{"note and nevent", note, nevent, false},
+		{"invalid codes", "npub1broken", "npub1other", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := codeKey(tt.a) == codeKey(tt.b); same != tt.same {
+				t.Fatalf("codeKey(%q) == codeKey(%q) is %v, want %v", tt.a, tt.b, same, tt.same)
+			}
+		})
+	}
+}
+
+func TestCoalescingRequestEvent(t *testing.T) {
+
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	article := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "intro"}}})
+	profile := signed(t, sk, nostr.Event{Kind: nostr.KindProfileMetadata})
+
+	npub, _ := nip19.EncodePublicKey(article.PubKey)
+	nprofile, _ := nip19.EncodeProfile(article.PubKey, nil)
+	naddr, _ := nip19.EncodeEntity(article.PubKey, article.Kind, "intro", nil)
+	hinted, _ := nip19.EncodeEntity(article.PubKey, article.Kind, "intro", []string{"wss://relay.example.com"})
+
+	next := &heldService{
+		events:  map[string]*nostr.Event{naddr: article, hinted: article, npub: profile},
+		release: make(chan struct{}),
+	}
+	s := NewCoalescing(next)
+
+	codes := []string{naddr, hinted, naddr, npub, nprofile}
+	events := make([]*nostr.Event, len(codes))
+	errs := make([]error, len(codes))
+
+	var wg sync.WaitGroup
+	for i, code := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			events[i], errs[i] = s.RequestEvent(ctx, code)
+		}()
+	}
+
+	// One call for the article, one for the npub and one for the nprofile,
+	// the others join the call in flight
+	waitFor(t, func() bool { return next.calls.Load() == 3 })
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	if n := next.calls.Load(); n != 3 {
+		t.Fatalf("got %d calls, want 3", n)
+	}
+	for i := range 3 {
+		if errs[i] != nil || events[i] != article {
+			t.Errorf("%s: got %v %v, want the article", codes[i], events[i], errs[i])
+		}
+	}
+	if errs[3] != nil || events[3] != profile {
+		t.Errorf("npub: got %v %v, want the profile", events[3], errs[3])
+	}
+	if errs[4] == nil {
+		t.Error("nprofile: got no error, want the one of its own call")
+	}
+}
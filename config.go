@@ -0,0 +1,124 @@
+package notezero
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dextryz/notezero/render"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Settings of the instance, read from the environment on startup.
+type Config struct {
+	// Providers whose links are embedded as iframes, see render.Providers.
+	// Set with NZ_EMBED_PROVIDERS, a comma separated list that can be empty.
+	EmbedProviders []string
+	// Leave articles with a NIP-36 content warning out of the list pages.
+	// Set with NZ_HIDE_SENSITIVE=true.
+	HideSensitive bool
+	// Send the newest version of a replaceable event to the relays that
+	// served a stale one. Set with NZ_REPUBLISH_STALE=true.
+	RepublishStale bool
+	// Size of the in-memory cache of events and rendered articles.
+	// Set with NZ_MEMORY_CACHE_MB.
+	MemoryCacheMB int
+	// Backend of the eventstore: badger, sqlite, lmdb or memory.
+	// Set with NZ_STORE.
+	Store string
+	// Where the backend keeps its data, a default per backend when empty.
+	// Set with NZ_STORE_PATH.
+	StorePath string
+	// Evict events that were not read for this many days, never when zero.
+	// Set with NZ_RETENTION_DAYS.
+	RetentionDays int
+	// Evict the least recently read events while they take more space, no
+	// limit when zero. Set with NZ_STORE_MAX_MB.
+	StoreMaxMB int
+	// Authors hosted by the instance, as hex public keys. Their events are
+	// mirrored from the relays and never evicted. Set with
+	// NZ_PINNED_AUTHORS, a comma separated list of npubs or hex keys.
+	PinnedAuthors []string
+	// Token that authorizes the admin endpoints, /admin/backup and
+	// /debug/vars, which are disabled when empty. Set with NZ_ADMIN_TOKEN.
+	AdminToken string
+}
+
+func ConfigFromEnv() Config {
+
+	cfg := Config{
+		EmbedProviders: render.Providers(),
+		MemoryCacheMB:  64,
+		Store:          "badger",
+	}
+
+	if v, ok := os.LookupEnv("NZ_EMBED_PROVIDERS"); ok {
+		cfg.EmbedProviders = splitList(v)
+	}
+
+	if v, err := strconv.ParseBool(os.Getenv("NZ_HIDE_SENSITIVE")); err == nil {
+		cfg.HideSensitive = v
+	}
+
+	if v, err := strconv.ParseBool(os.Getenv("NZ_REPUBLISH_STALE")); err == nil {
+		cfg.RepublishStale = v
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("NZ_MEMORY_CACHE_MB")); err == nil && v >= 0 {
+		cfg.MemoryCacheMB = v
+	}
+
+	if v := strings.TrimSpace(os.Getenv("NZ_STORE")); v != "" {
+		cfg.Store = strings.ToLower(v)
+	}
+
+	cfg.StorePath = os.Getenv("NZ_STORE_PATH")
+
+	if v, err := strconv.Atoi(os.Getenv("NZ_RETENTION_DAYS")); err == nil && v >= 0 {
+		cfg.RetentionDays = v
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("NZ_STORE_MAX_MB")); err == nil && v >= 0 {
+		cfg.StoreMaxMB = v
+	}
+
+	cfg.PinnedAuthors = pubkeys(splitList(os.Getenv("NZ_PINNED_AUTHORS")))
+
+	cfg.AdminToken = os.Getenv("NZ_ADMIN_TOKEN")
+
+	return cfg
+}
+
+// Retention policy of the stored events.
+func (c Config) Retention() Retention {
+	return Retention{
+		MaxAge:   time.Duration(c.RetentionDays) * 24 * time.Hour,
+		MaxBytes: int64(c.StoreMaxMB) * 1024 * 1024,
+		Pinned:   c.PinnedAuthors,
+	}
+}
+
+// Hex public keys of a list of npubs or hex keys, skipping invalid ones.
+func pubkeys(list []string) []string {
+	keys := []string{}
+	for _, v := range list {
+		if prefix, data, err := nip19.Decode(v); err == nil && prefix == "npub" {
+			keys = append(keys, data.(string))
+		} else if nostr.IsValidPublicKeyHex(v) {
+			keys = append(keys, v)
+		}
+	}
+	return keys
+}
+
+func splitList(v string) []string {
+	list := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, strings.ToLower(item))
+		}
+	}
+	return list
+}
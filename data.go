@@ -2,9 +2,14 @@ package notezero
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/dextryz/notezero/render"
+	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
@@ -34,6 +39,7 @@ type Data struct {
 	ModifiedAt string
 	Kind       string
 	Content    string
+	Outline    []render.Heading
 }
 
 // FIXME: Remove the content bool hack
@@ -73,6 +79,11 @@ func (s *Handler) requestData(ctx context.Context, code string, content bool) (*
 	switch rootEvent.Kind {
 	case 0:
 		data.TemplateId = ListArticle
+		if profile, err := ParseMetadata(*rootEvent); err == nil {
+			data.Metadata = *profile
+			// The about text can use custom emoji from the tags of the profile
+			data.Content = s.renderer.RenderText(ctx, rootEvent, profile.About).HTML
+		}
 		events, err := s.service.AuthorArticles(ctx, npub)
 		if err != nil {
 			return nil, err
@@ -83,10 +94,13 @@ func (s *Handler) requestData(ctx context.Context, code string, content bool) (*
 		for _, e := range events {
 			data.Notes = append(data.Notes, EnhancedEvent{Event: e})
 		}
+		s.mem.withStats(data.Notes)
 	case 30023:
 
 		data.TemplateId = Article
-		data.Content = mdToHtml(rootEvent.Content)
+		data.Event.stats = s.mem.noteStats(rootEvent)
+
+		highlights := []*nostr.Event{}
 
 		if content {
 
@@ -95,33 +109,71 @@ func (s *Handler) requestData(ctx context.Context, code string, content bool) (*
 			// 3. Add the highlights to the data.Notes list
 			if d := rootEvent.Tags.GetFirst([]string{"d", ""}); d != nil {
 
-				events, err := s.service.ArticleHighlights(ctx, rootEvent.Kind, rootEvent.PubKey, d.Value())
+				highlights, err = s.service.ArticleHighlights(ctx, rootEvent.Kind, rootEvent.PubKey, d.Value())
 				if err != nil {
 					return nil, err
 				}
 
 				// Add highlight notes to article data structure after applying to content
-				for _, v := range events {
+				for _, v := range highlights {
 					data.Notes = append(data.Notes, EnhancedEvent{Event: v})
 				}
+			}
+		}
 
-				highlights := []string{}
-				for _, v := range events {
-					highlights = append(highlights, v.Content)
-					fmt.Println(v.Content)
-				}
+		// Rendering is skipped if the article and its highlights did not change
+		key := htmlCacheKey(rootEvent, highlights)
+		if v, ok := s.mem.Get(key); ok {
+			doc := v.(render.Document)
+			data.Content = doc.HTML
+			data.Outline = doc.Outline
+			break
+		}
+
+		doc := s.renderer.RenderEvent(ctx, rootEvent)
+		data.Content = doc.HTML
+		data.Outline = doc.Outline
+
+		if len(highlights) != 0 {
 
-				intervals := highlightIntervals(data.Content, highlights)
-				fmt.Println(intervals)
-				merged := mergeIntervals(intervals)
-				fmt.Println(merged)
-				data.Content = highlight(data.Content, merged)
+			contents := []string{}
+			for _, v := range highlights {
+				contents = append(contents, v.Content)
 			}
+
+			intervals := highlightIntervals(data.Content, contents)
+			merged := mergeIntervals(intervals)
+			data.Content = highlight(data.Content, merged)
 		}
 
+		s.mem.Put(key, eventAddress(rootEvent), render.Document{HTML: data.Content, Outline: data.Outline}, documentSize(data.Content, data.Outline))
+
 	default:
 		data.TemplateId = Unkown
 	}
 
 	return data, nil
 }
+
+// html:<address>:<id>:<hash>, where the hash covers the set of highlights, so
+// a new version or a new highlight renders the article again.
+func htmlCacheKey(e *nostr.Event, highlights []*nostr.Event) string {
+
+	ids := []string{}
+	for _, h := range highlights {
+		ids = append(ids, h.ID)
+	}
+	slices.Sort(ids)
+
+	hash := sha256.Sum256([]byte(strings.Join(ids, ",")))
+
+	return fmt.Sprintf("html:%s:%s:%x", eventAddress(e), e.ID, hash[:8])
+}
+
+func documentSize(html string, outline []render.Heading) int64 {
+	size := len(html)
+	for _, h := range outline {
+		size += len(h.ID) + len(h.Text) + 16
+	}
+	return int64(size)
+}
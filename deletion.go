@@ -0,0 +1,142 @@
+package notezero
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Request the NIP-09 deletions of the events from the relays. Each deletion is
+// saved, which removes the events it refers to from the store.
+func (s eventService) fetchDeletions(ctx context.Context, events []*nostr.Event) {
+
+	ids := []string{}
+	addresses := []string{}
+	for _, e := range events {
+		ids = append(ids, e.ID)
+		if isAddressable(e) {
+			addresses = append(addresses, eventAddress(e))
+		}
+	}
+
+	filters := nostr.Filters{}
+	if len(ids) != 0 {
+		filters = append(filters, nostr.Filter{Kinds: []int{nostr.KindDeletion}, Tags: nostr.TagMap{"e": ids}})
+	}
+	if len(addresses) != 0 {
+		filters = append(filters, nostr.Filter{Kinds: []int{nostr.KindDeletion}, Tags: nostr.TagMap{"a": addresses}})
+	}
+	if len(filters) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	for ie := range s.subscribe(ctx, filters) {
+		s.save(ctx, ie.Event)
+	}
+}
+
+// Remove the events a deletion refers to. Only the author of an event can
+// delete it, and an address is only deleted up to the time of the deletion.
+func (s eventService) applyDeletion(ctx context.Context, deletion *nostr.Event) error {
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	targets := []*nostr.Event{}
+
+	ids := []string{}
+	for _, t := range deletion.Tags {
+		if t.Key() == "e" && t.Value() != "" {
+			ids = append(ids, t.Value())
+		}
+	}
+	if len(ids) != 0 {
+		events, err := wdb.QuerySync(ctx, nostr.Filter{IDs: ids})
+		if err != nil {
+			return err
+		}
+		targets = append(targets, events...)
+	}
+
+	for _, t := range deletion.Tags {
+		if t.Key() != "a" {
+			continue
+		}
+		kind, pubkey, identifier, ok := parseAddress(t.Value())
+		if !ok || pubkey != deletion.PubKey {
+			continue
+		}
+		events, err := wdb.QuerySync(ctx, nostr.Filter{
+			Kinds:   []int{kind},
+			Authors: []string{pubkey},
+			Tags:    nostr.TagMap{"d": []string{identifier}},
+			Until:   &deletion.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		targets = append(targets, events...)
+	}
+
+	for _, e := range targets {
+		if e.PubKey != deletion.PubKey || e.Kind == nostr.KindDeletion {
+			continue
+		}
+		err := s.deleteEvent(ctx, e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// True if the store has a deletion of the event by its author, so relays
+// that did not process the deletion cannot bring it back.
+func (s eventService) isDeleted(ctx context.Context, e *nostr.Event) (bool, error) {
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	filters := nostr.Filters{
+		{Kinds: []int{nostr.KindDeletion}, Authors: []string{e.PubKey}, Tags: nostr.TagMap{"e": []string{e.ID}}},
+	}
+	if isAddressable(e) {
+		filters = append(filters, nostr.Filter{
+			Kinds:   []int{nostr.KindDeletion},
+			Authors: []string{e.PubKey},
+			Tags:    nostr.TagMap{"a": []string{eventAddress(e)}},
+			Since:   &e.CreatedAt,
+		})
+	}
+
+	for _, f := range filters {
+		deletions, err := wdb.QuerySync(ctx, f)
+		if err != nil {
+			return false, err
+		}
+		if len(deletions) != 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Split an "a" tag value of the form kind:pubkey:d.
+func parseAddress(address string) (kind int, pubkey, identifier string, ok bool) {
+	parts := strings.SplitN(address, ":", 3)
+	if len(parts) != 3 {
+		return 0, "", "", false
+	}
+	kind, err := strconv.Atoi(parts[0])
+	if err != nil || !nostr.IsValidPublicKeyHex(parts[1]) {
+		return 0, "", "", false
+	}
+	return kind, parts[1], parts[2], true
+}
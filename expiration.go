@@ -0,0 +1,88 @@
+package notezero
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// NIP-40 expiration tag, zero if the event does not expire.
+func expiration(e *nostr.Event) nostr.Timestamp {
+	if v := tagValue(e, "expiration"); v != "" {
+		if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return nostr.Timestamp(ts)
+		}
+	}
+	return 0
+}
+
+func isExpired(e *nostr.Event) bool {
+	exp := expiration(e)
+	return exp != 0 && exp <= nostr.Now()
+}
+
+// The timestamp is zero padded, so the keys are sorted by expiration and the
+// sweeper can stop at the first one still in the future.
+func expirationKey(exp nostr.Timestamp, id string) string {
+	return cacheKey(nsExpiration, fmt.Sprintf("%020d", exp), id)
+}
+
+func (s eventService) indexExpiration(e *nostr.Event) error {
+	if exp := expiration(e); exp != 0 {
+		return s.cache.Set(expirationKey(exp, e.ID), nil, 0)
+	}
+	return nil
+}
+
+// Remove the events that expired from the store, and return how many.
+func (s eventService) SweepExpired(ctx context.Context) (int, error) {
+
+	prefix := cacheKey(nsExpiration)
+
+	keys, err := s.cache.Keys(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+	now := nostr.Now()
+	count := 0
+
+	for _, key := range keys {
+
+		parts := strings.SplitN(strings.TrimPrefix(key, prefix), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		exp, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if nostr.Timestamp(exp) > now {
+			break
+		}
+
+		events, err := wdb.QuerySync(ctx, nostr.Filter{IDs: []string{parts[1]}})
+		if err != nil {
+			return count, err
+		}
+		for _, e := range events {
+			err := s.deleteEvent(ctx, e)
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		err = s.cache.Delete(key)
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
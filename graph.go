@@ -0,0 +1,243 @@
+package notezero
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dextryz/notezero/render"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	EdgeLink    = "link"
+	EdgeHashtag = "hashtag"
+)
+
+// The articles of an author and how they relate to each other.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	ID          string   `json:"id"` // Address of the article, kind:pubkey:d
+	Naddr       string   `json:"naddr"`
+	Title       string   `json:"title"`
+	Tags        []string `json:"tags"`
+	PublishedAt string   `json:"published_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+// Link edges point from the linking article to the linked one. Hashtag edges
+// are undirected and list every tag the two articles share.
+type GraphEdge struct {
+	Source string   `json:"source"`
+	Target string   `json:"target"`
+	Type   string   `json:"type"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// Build the graph of a single author's articles.
+// 1. Every article is a node
+// 2. naddr references, "a" tags and wikilinks to another article become link edges
+// 3. Articles sharing hashtags are connected by a single hashtag edge
+func BuildGraph(events []*nostr.Event) Graph {
+
+	g := Graph{
+		Nodes: []GraphNode{},
+		Edges: []GraphEdge{},
+	}
+
+	byAddress := map[string]*nostr.Event{}
+	byTitle := map[string]*nostr.Event{}
+
+	for _, e := range events {
+		byAddress[eventAddress(e)] = e
+		if title := strings.ToLower(tagValue(e, "title")); title != "" {
+			byTitle[title] = e
+		}
+	}
+
+	for _, e := range events {
+		ee := EnhancedEvent{Event: e}
+		g.Nodes = append(g.Nodes, GraphNode{
+			ID:          eventAddress(e),
+			Naddr:       ee.Naddr(),
+			Title:       ee.Title(),
+			Tags:        graphTags(e),
+			PublishedAt: graphTime(ee.PublishedAt()),
+			UpdatedAt:   graphTime(e.CreatedAt),
+		})
+	}
+
+	for _, e := range events {
+
+		source := eventAddress(e)
+		linked := map[string]bool{}
+
+		link := func(target *nostr.Event) {
+			if target == nil || target == e || linked[eventAddress(target)] {
+				return
+			}
+			linked[eventAddress(target)] = true
+			g.Edges = append(g.Edges, GraphEdge{
+				Source: source,
+				Target: eventAddress(target),
+				Type:   EdgeLink,
+			})
+		}
+
+		for _, address := range referencedAddresses(e) {
+			link(byAddress[address])
+		}
+
+		// Wikilinks resolve by d tag first, as in the renderer
+		for _, target := range render.WikiLinks(e.Content) {
+			if t, ok := byAddress[fmt.Sprintf("%d:%s:%s", nostr.KindArticle, e.PubKey, target)]; ok {
+				link(t)
+			} else {
+				link(byTitle[strings.ToLower(target)])
+			}
+		}
+	}
+
+	for i, a := range g.Nodes {
+		for _, b := range g.Nodes[i+1:] {
+			shared := []string{}
+			for _, tag := range a.Tags {
+				if slices.Contains(b.Tags, tag) {
+					shared = append(shared, tag)
+				}
+			}
+			if len(shared) == 0 {
+				continue
+			}
+			g.Edges = append(g.Edges, GraphEdge{
+				Source: a.ID,
+				Target: b.ID,
+				Type:   EdgeHashtag,
+				Tags:   shared,
+			})
+		}
+	}
+
+	return g
+}
+
+// Hashtags are case-insensitive, so #Go and #go connect the same articles.
+func graphTags(e *nostr.Event) []string {
+	tags := []string{}
+	for _, t := range e.Tags {
+		if t.Key() != "t" || t.Value() == "" {
+			continue
+		}
+		tag := strings.ToLower(t.Value())
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func graphTime(ts nostr.Timestamp) string {
+	return time.Unix(int64(ts), 0).UTC().Format(time.RFC3339)
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLItem `xml:"node"`
+	Edges       []graphMLItem `xml:"edge"`
+}
+
+type graphMLItem struct {
+	ID       string        `xml:"id,attr,omitempty"`
+	Source   string        `xml:"source,attr,omitempty"`
+	Target   string        `xml:"target,attr,omitempty"`
+	Directed string        `xml:"directed,attr,omitempty"`
+	Data     []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Write the graph as GraphML, which Gephi, yEd and Cytoscape can import.
+// Tags are joined by commas, since GraphML has no list type.
+func (g Graph) WriteGraphML(w io.Writer) error {
+
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "title", For: "node", Name: "title", Type: "string"},
+			{ID: "naddr", For: "node", Name: "naddr", Type: "string"},
+			{ID: "tags", For: "node", Name: "tags", Type: "string"},
+			{ID: "published_at", For: "node", Name: "published_at", Type: "string"},
+			{ID: "updated_at", For: "node", Name: "updated_at", Type: "string"},
+			{ID: "type", For: "edge", Name: "type", Type: "string"},
+			{ID: "shared_tags", For: "edge", Name: "shared_tags", Type: "string"},
+			{ID: "weight", For: "edge", Name: "weight", Type: "int"},
+		},
+		Graph: graphMLGraph{
+			EdgeDefault: "directed",
+		},
+	}
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLItem{
+			ID: n.ID,
+			Data: []graphMLData{
+				{Key: "title", Value: n.Title},
+				{Key: "naddr", Value: n.Naddr},
+				{Key: "tags", Value: strings.Join(n.Tags, ",")},
+				{Key: "published_at", Value: n.PublishedAt},
+				{Key: "updated_at", Value: n.UpdatedAt},
+			},
+		})
+	}
+
+	for _, e := range g.Edges {
+		item := graphMLItem{
+			Source: e.Source,
+			Target: e.Target,
+			Data:   []graphMLData{{Key: "type", Value: e.Type}},
+		}
+		if e.Type == EdgeHashtag {
+			item.Directed = "false"
+			item.Data = append(item.Data,
+				graphMLData{Key: "shared_tags", Value: strings.Join(e.Tags, ",")},
+				graphMLData{Key: "weight", Value: strconv.Itoa(len(e.Tags))},
+			)
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, item)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	return enc.Encode(doc)
+}
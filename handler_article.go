@@ -7,6 +7,8 @@ import (
 	"net/http"
 
 	"github.com/a-h/templ"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
 // 1. Highlights are encoded into data.Notes
@@ -29,9 +31,18 @@ func (s *Handler) ContentHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch data.TemplateId {
 	case Article:
+		highlights := []HighlightParams{}
+		for _, note := range data.Notes {
+			h := HighlightParams{Event: note}
+			if comment := note.Comment(); comment != "" {
+				h.Comment = template.HTML(s.renderer.RenderText(r.Context(), note.Event, comment).HTML)
+			}
+			highlights = append(highlights, h)
+		}
 		component = ContentTemplate(ArticleParams{
-			Event:   data.Event,
-			Content: template.HTML(data.Content), // data.Content is converted from Md to Html in data service.
+			Event:      data.Event,
+			Content:    template.HTML(data.Content), // data.Content is converted from Md to Html in data service.
+			Highlights: highlights,
 		})
 	default:
 		s.log.Error("unable to render template", "templateId", data.TemplateId)
@@ -51,6 +62,13 @@ func (s *Handler) ArticleHandler(w http.ResponseWriter, r *http.Request) {
 	code := r.PathValue("naddr")
 	npub := r.PathValue("npub")
 
+	// The content was served on /nz/content/{naddr} before it moved under the
+	// author, which "content" can never be
+	if npub == "content" {
+		redirectContent(w, r, code)
+		return
+	}
+
 	s.log.Info("handler for article", "naddr", code, "npub", npub)
 
 	data, err := s.requestData(r.Context(), code, false)
@@ -69,6 +87,7 @@ func (s *Handler) ArticleHandler(w http.ResponseWriter, r *http.Request) {
 		component = ArticleTemplate(ArticleParams{
 			Event:   data.Event,
 			Content: template.HTML(data.Content), // data.Content is converted from Md to Html in data service.
+			Outline: data.Outline,
 		})
 	default:
 		s.log.Error("unable to render template", "templateId", data.TemplateId)
@@ -81,3 +100,56 @@ func (s *Handler) ArticleHandler(w http.ResponseWriter, r *http.Request) {
 		s.log.Error("error rendering tmpl", "error", err.Error())
 	}
 }
+
+// Redirect the old content path of an article to the one under its author.
+func redirectContent(w http.ResponseWriter, r *http.Request, naddr string) {
+
+	_, data, err := nip19.Decode(naddr)
+	ep, ok := data.(nostr.EntityPointer)
+	if err != nil || !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	npub, err := nip19.EncodePublicKey(ep.PublicKey)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/nz/%s/%s/content", npub, naddr), http.StatusMovedPermanently)
+}
+
+// Articles and notes that link to the article, loaded after the article itself.
+func (s *Handler) BacklinksHandler(w http.ResponseWriter, r *http.Request) {
+
+	code := r.PathValue("naddr")
+
+	prefix, data, err := nip19.Decode(code)
+	if err != nil || prefix != "naddr" {
+		http.Error(w, "invalid naddr", http.StatusBadRequest)
+		return
+	}
+	ep := data.(nostr.EntityPointer)
+
+	events, err := s.service.ArticleBacklinks(r.Context(), ep.Kind, ep.PublicKey, ep.Identifier)
+	if err != nil {
+		s.log.Error("failed to get backlinks", slog.Any("error", err))
+		http.Error(w, "failed to get backlinks", http.StatusInternalServerError)
+		return
+	}
+
+	notes := []EnhancedEvent{}
+	for _, e := range events {
+		notes = append(notes, EnhancedEvent{Event: e})
+	}
+
+	s.log.Info("rendering backlinks view", "naddr", code, "backlinkCount", len(notes))
+
+	err = BacklinksTemplate(BacklinksParams{
+		Notes: notes,
+	}).Render(r.Context(), w)
+	if err != nil {
+		s.log.Error("error rendering tmpl", "error", err.Error())
+	}
+}
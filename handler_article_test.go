@@ -0,0 +1,39 @@
+package notezero
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestRedirectContent(t *testing.T) {
+
+	pk, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	npub, _ := nip19.EncodePublicKey(pk)
+	naddr, _ := nip19.EncodeEntity(pk, nostr.KindArticle, "intro", nil)
+
+	h := &Handler{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /nz/{npub}/{naddr}", h.ArticleHandler)
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/nz/content/" + naddr, http.StatusMovedPermanently, "/nz/" + npub + "/" + naddr + "/content"},
+		{"/nz/content/" + npub, http.StatusNotFound, ""},
+		{"/nz/content/invalid", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: got %d %q, want %d %q", tt.path, w.Code, w.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+}
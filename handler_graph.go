@@ -0,0 +1,55 @@
+package notezero
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// The articles of an author as a graph in JSON, for visualisation tools.
+func (s *Handler) GraphHandler(w http.ResponseWriter, r *http.Request) {
+
+	npub := r.PathValue("npub")
+
+	events, err := s.service.AuthorArticles(r.Context(), npub)
+	if err != nil {
+		s.log.Error("failed to get articles", slog.Any("error", err))
+		http.Error(w, "failed to get articles", http.StatusInternalServerError)
+		return
+	}
+
+	graph := BuildGraph(events)
+
+	s.log.Info("rendering graph", "author", npub, "nodeCount", len(graph.Nodes), "edgeCount", len(graph.Edges))
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(graph)
+	if err != nil {
+		s.log.Error("error encoding graph", "error", err.Error())
+	}
+}
+
+// Same graph as GraphHandler, in GraphML for Gephi, yEd and the like.
+func (s *Handler) GraphMLHandler(w http.ResponseWriter, r *http.Request) {
+
+	npub := r.PathValue("npub")
+
+	events, err := s.service.AuthorArticles(r.Context(), npub)
+	if err != nil {
+		s.log.Error("failed to get articles", slog.Any("error", err))
+		http.Error(w, "failed to get articles", http.StatusInternalServerError)
+		return
+	}
+
+	graph := BuildGraph(events)
+
+	s.log.Info("rendering graphml", "author", npub, "nodeCount", len(graph.Nodes), "edgeCount", len(graph.Edges))
+
+	w.Header().Set("Content-Type", "application/graphml+xml")
+
+	err = graph.WriteGraphML(w)
+	if err != nil {
+		s.log.Error("error encoding graph", "error", err.Error())
+	}
+}
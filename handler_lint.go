@@ -0,0 +1,32 @@
+package notezero
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// List every article of an author that has an incorrect format.
+func (s *Handler) LintHandler(w http.ResponseWriter, r *http.Request) {
+
+	npub := r.PathValue("npub")
+
+	events, err := s.service.AuthorArticles(r.Context(), npub)
+	if err != nil {
+		s.log.Error("failed to get articles", slog.Any("error", err))
+		http.Error(w, "failed to get articles", http.StatusInternalServerError)
+		return
+	}
+
+	reports := LintArticles(events)
+
+	s.log.Info("rendering lint view", "author", npub, "articleCount", len(events), "reportCount", len(reports))
+
+	err = LintTemplate(LintParams{
+		Npub:         npub,
+		ArticleCount: len(events),
+		Reports:      reports,
+	}).Render(r.Context(), w)
+	if err != nil {
+		s.log.Error("error rendering tmpl", "error", err.Error())
+	}
+}
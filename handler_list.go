@@ -2,24 +2,41 @@ package notezero
 
 import (
 	"fmt"
+	"html/template"
 	"log/slog"
 	"net/http"
 
 	"github.com/a-h/templ"
+	"github.com/dextryz/notezero/render"
 )
 
 type Handler struct {
-	log     *slog.Logger
-	service EventService
+	log      *slog.Logger
+	service  EventService
+	renderer *render.Renderer
+	cfg      Config
+	mem      *MemoryCache
 }
 
-func NewHandler(log *slog.Logger, es EventService) *Handler {
+func NewHandler(log *slog.Logger, es EventService, cfg Config) *Handler {
 	return &Handler{
 		log:     log,
 		service: es,
+		renderer: render.New(render.Options{
+			HrefTargetBlank: true,
+			Fetcher:         es,
+			EmbedProviders:  cfg.EmbedProviders,
+		}),
+		cfg: cfg,
 	}
 }
 
+// Keep rendered articles in memory.
+func (s *Handler) WithMemoryCache(mem *MemoryCache) *Handler {
+	s.mem = mem
+	return s
+}
+
 func (s *Handler) Homepage(w http.ResponseWriter, r *http.Request) {
 	IndexTemplate().Render(r.Context(), w)
 }
@@ -46,8 +63,17 @@ func (s *Handler) CodeHandler(w http.ResponseWriter, r *http.Request) {
 	// 2. A list of highlights are returned is the search field was nevent of kind 30023
 	switch data.TemplateId {
 	case ListArticle:
+		notes := data.Notes
+		if lang := r.URL.Query().Get("lang"); lang != "" {
+			notes = filterLanguage(notes, lang)
+		}
+		if s.cfg.HideSensitive {
+			notes = filterSensitive(notes)
+		}
 		component = ListArticleTemplate(ListArticleParams{
-			Notes: data.Notes,
+			Notes:   notes,
+			Profile: data.Metadata,
+			About:   template.HTML(data.Content), // data.Content is the about text converted from Md to Html in data service.
 		})
 		fmt.Println("Component")
 		fmt.Println(len(data.Notes))
@@ -62,3 +88,38 @@ func (s *Handler) CodeHandler(w http.ResponseWriter, r *http.Request) {
 		s.log.Error("error rendering tmpl", "error", err.Error())
 	}
 }
+
+// Articles from any author with the hashtag.
+func (s *Handler) TagHandler(w http.ResponseWriter, r *http.Request) {
+
+	tag := r.PathValue("tag")
+
+	events, err := s.service.TagArticles(r.Context(), tag)
+	if err != nil {
+		s.log.Error("failed to get articles", slog.Any("error", err))
+		http.Error(w, "failed to get articles", http.StatusInternalServerError)
+		return
+	}
+
+	notes := []EnhancedEvent{}
+	for _, e := range events {
+		notes = append(notes, EnhancedEvent{Event: e})
+	}
+	s.mem.withStats(notes)
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		notes = filterLanguage(notes, lang)
+	}
+	if s.cfg.HideSensitive {
+		notes = filterSensitive(notes)
+	}
+
+	s.log.Info("rendering tag view", "tag", tag, "noteCount", len(notes))
+
+	err = ListArticleTemplate(ListArticleParams{
+		Notes: notes,
+		Tag:   tag,
+	}).Render(r.Context(), w)
+	if err != nil {
+		s.log.Error("error rendering tmpl", "error", err.Error())
+	}
+}
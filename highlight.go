@@ -21,7 +21,7 @@ func max(x, y int) int {
 func highlightIntervals(content string, highlights []string) [][]int {
 	res := [][]int{}
 	for _, v := range highlights {
-		start := strings.Index(content, v)
+		start := indexText(content, v)
 		if start == -1 {
 			// TODO pass event so we can properly log this.
 			log.Println("highlight not found in article")
@@ -33,6 +33,32 @@ func highlightIntervals(content string, highlights []string) [][]int {
 	return res
 }
 
+// Index of the first occurrence of s in the text of the html content.
+// Matches inside a tag, like the class names of highlighted code, or matches
+// that span across tags are skipped, since wrapping them breaks the markup.
+func indexText(content, s string) int {
+
+	if s == "" || strings.ContainsAny(s, "<>") {
+		return -1
+	}
+
+	offset := 0
+	for {
+		i := strings.Index(content[offset:], s)
+		if i == -1 {
+			return -1
+		}
+		start := offset + i
+
+		// Inside a tag if the last bracket before the match opened one
+		if strings.LastIndex(content[:start], "<") <= strings.LastIndex(content[:start], ">") {
+			return start
+		}
+
+		offset = start + 1
+	}
+}
+
 func mergeIntervals(intervals [][]int) [][]int {
 
 	if len(intervals) == 0 {
@@ -0,0 +1,126 @@
+package notezero
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// Clocks drift, but an event from the future is most likely forged to stay
+	// on top of every list.
+	maxFutureDrift = 15 * time.Minute
+	// Larger than maxArticleSize, which the lint still reports on, so authors
+	// can see why their article is too large.
+	maxEventSize = 512 * 1024
+)
+
+type rejection string
+
+const (
+	rejectInvalidID        rejection = "invalid_id"
+	rejectInvalidSignature rejection = "invalid_signature"
+	rejectFutureTimestamp  rejection = "future_timestamp"
+	rejectOversized        rejection = "oversized"
+	rejectFilterMismatch   rejection = "filter_mismatch"
+)
+
+// Events rejected by the ingestion gate, per relay and reason. Served as
+// JSON on /debug/vars.
+var rejectedEvents = expvar.NewMap("rejected_events")
+
+var rejectedMu sync.Mutex
+
+func countRejection(relay string, reason rejection) {
+	rejectedMu.Lock()
+	defer rejectedMu.Unlock()
+
+	m, ok := rejectedEvents.Get(relay).(*expvar.Map)
+	if !ok {
+		m = new(expvar.Map)
+		rejectedEvents.Set(relay, m)
+	}
+	m.Add(string(reason), 1)
+}
+
+// The reason to reject an event a relay sent for the filters, if any. Nil
+// filters match every event. Cheap checks go first, the signature is only
+// verified if all else is fine.
+func checkEvent(e *nostr.Event, filters nostr.Filters) (rejection, bool) {
+
+	if len(e.Content) > maxEventSize {
+		return rejectOversized, false
+	}
+
+	if e.CreatedAt.Time().After(time.Now().Add(maxFutureDrift)) {
+		return rejectFutureTimestamp, false
+	}
+
+	if filters != nil && !filters.Match(e) {
+		return rejectFilterMismatch, false
+	}
+
+	if e.GetID() != e.ID {
+		return rejectInvalidID, false
+	}
+
+	if ok, err := e.CheckSignature(); err != nil || !ok {
+		return rejectInvalidSignature, false
+	}
+
+	return "", true
+}
+
+// Returned by Import for events that fail the ingestion checks.
+var ErrRejected = errors.New("event rejected")
+
+// Store an event from an export or another instance, with the same checks and
+// reconciliation as the events from relays: stale versions of replaceable
+// events and deleted events are not stored.
+func (s eventService) Import(ctx context.Context, e *nostr.Event) error {
+	reason, ok := checkEvent(e, nil)
+	if !ok {
+		return fmt.Errorf("%w: %s: %s", ErrRejected, e.ID, reason)
+	}
+	return s.save(ctx, e)
+}
+
+// Every event received from a relay goes through the ingestion gate before it
+// is saved or shown.
+func (s eventService) accept(relay string, e *nostr.Event, filters nostr.Filters) bool {
+	reason, ok := checkEvent(e, filters)
+	if !ok {
+		countRejection(relay, reason)
+	}
+	return ok
+}
+
+// Request the filters from all relays until they reach the end of their stored
+// events. Only the events that pass the ingestion gate are sent on.
+func (s eventService) subscribe(ctx context.Context, filters nostr.Filters) <-chan nostr.IncomingEvent {
+
+	pool := nostr.NewSimplePool(ctx)
+
+	out := make(chan nostr.IncomingEvent)
+
+	go func() {
+		defer close(out)
+		for ie := range pool.SubManyEose(ctx, s.relays, filters) {
+			if !s.accept(ie.Relay.URL, ie.Event, filters) {
+				continue
+			}
+			select {
+			case out <- ie:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,90 @@
+package notezero
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestCheckEvent(t *testing.T) {
+
+	sk := nostr.GeneratePrivateKey()
+	other := nostr.GeneratePrivateKey()
+
+	note := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, Content: "hello"})
+
+	tampered := *note
+	tampered.Content = "goodbye"
+
+	forged := *note
+	forged.Sig = signed(t, other, nostr.Event{Kind: nostr.KindTextNote, Content: "hello", CreatedAt: note.CreatedAt}).Sig
+
+	future := nostr.Timestamp(time.Now().Add(2 * maxFutureDrift).Unix())
+	drifted := nostr.Timestamp(time.Now().Add(maxFutureDrift / 2).Unix())
+
+	tests := []struct {
+		name    string
+		event   *nostr.Event
+		filters nostr.Filters
+		reason  rejection
+	}{
+		{
+			name:  "valid",
+			event: note,
+		},
+		{
+			name:    "matches filters",
+			event:   note,
+			filters: nostr.Filters{{Kinds: []int{nostr.KindArticle}}, {Kinds: []int{nostr.KindTextNote}}},
+		},
+		{
+			name:   "slight clock drift",
+			event:  signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, CreatedAt: drifted}),
+			reason: "",
+		},
+		{
+			name:   "invalid id",
+			event:  &tampered,
+			reason: rejectInvalidID,
+		},
+		{
+			name:   "invalid signature",
+			event:  &forged,
+			reason: rejectInvalidSignature,
+		},
+		{
+			name:   "future timestamp",
+			event:  signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, CreatedAt: future}),
+			reason: rejectFutureTimestamp,
+		},
+		{
+			name:   "oversized",
+			event:  signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Content: strings.Repeat("a", maxEventSize+1)}),
+			reason: rejectOversized,
+		},
+		{
+			name:    "filter mismatch",
+			event:   note,
+			filters: nostr.Filters{{Kinds: []int{nostr.KindArticle}}},
+			reason:  rejectFilterMismatch,
+		},
+		{
+			// Cheap checks go first
+			name:    "oversized and mismatching",
+			event:   &nostr.Event{Kind: nostr.KindTextNote, Content: strings.Repeat("a", maxEventSize+1)},
+			filters: nostr.Filters{{Kinds: []int{nostr.KindArticle}}},
+			reason:  rejectOversized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := checkEvent(tt.event, tt.filters)
+			if reason != tt.reason || ok != (tt.reason == "") {
+				t.Fatalf("got %q %v, want %q", reason, ok, tt.reason)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package notezero
 
 import (
 	"context"
+	"time"
 
 	"github.com/nbd-wtf/go-nostr"
 )
@@ -9,5 +10,21 @@ import (
 type EventService interface {
 	RequestEvent(ctx context.Context, code string) (*nostr.Event, error)
 	AuthorArticles(ctx context.Context, npub string) ([]*nostr.Event, error)
+	TagArticles(ctx context.Context, tag string) ([]*nostr.Event, error)
 	ArticleHighlights(ctx context.Context, kind int, pubkey, identifier string) ([]*nostr.Event, error)
+	ArticleBacklinks(ctx context.Context, kind int, pubkey, identifier string) ([]*nostr.Event, error)
+	FetchEvents(ctx context.Context, filters nostr.Filters) ([]*nostr.Event, error)
+}
+
+// Key-value store next to the eventstore, with one implementation per
+// backend. Keys are namespaced, see cacheKey, and can expire.
+type Cache interface {
+	// The value of the key, false if it does not exist or expired.
+	Get(key string) ([]byte, bool, error)
+	// Set the value of the key, which expires after the ttl or never when
+	// the ttl is zero.
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	// All keys that start with the prefix, in order.
+	Keys(prefix string) ([]string, error)
 }
@@ -0,0 +1,190 @@
+package notezero
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Articles larger than this are most likely pasted binaries or broken exports.
+const maxArticleSize = 100 * 1024
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+type Problem struct {
+	Severity Severity
+	Message  string
+}
+
+// A list of format problems found in a single kind 30023 event.
+type ArticleReport struct {
+	Event    EnhancedEvent
+	Problems []Problem
+}
+
+func (s ArticleReport) Name() string {
+	if title := s.Event.Title(); title != "" {
+		return title
+	}
+	if d := s.Event.Tags.GetFirst([]string{"d", ""}); d != nil && d.Value() != "" {
+		return d.Value()
+	}
+	return s.Event.ID
+}
+
+var (
+	nostrReference = regexp.MustCompile(`nostr:([a-z0-9]+)`)
+	unclosedLink   = regexp.MustCompile(`\[[^\]]*\]\([^)]*$`)
+)
+
+// Lint every article and only return the ones that have problems.
+func LintArticles(events []*nostr.Event) []ArticleReport {
+	reports := []ArticleReport{}
+	for _, e := range events {
+		problems := LintArticle(e)
+		if len(problems) == 0 {
+			continue
+		}
+		reports = append(reports, ArticleReport{
+			Event:    EnhancedEvent{Event: e},
+			Problems: problems,
+		})
+	}
+	return reports
+}
+
+// Validate an article against NIP-23.
+// 1. Required tags (d, title) are errors, since we cannot show the article without them
+// 2. Recommended tags (summary, image, published_at, t) are warnings
+// 3. Content is checked for broken nostr: references and markdown that will not render
+func LintArticle(e *nostr.Event) []Problem {
+
+	problems := []Problem{}
+
+	errorf := func(format string, a ...any) {
+		problems = append(problems, Problem{SeverityError, fmt.Sprintf(format, a...)})
+	}
+	warnf := func(format string, a ...any) {
+		problems = append(problems, Problem{SeverityWarning, fmt.Sprintf(format, a...)})
+	}
+
+	if e.Kind != nostr.KindArticle {
+		errorf("event is kind %d, not %d", e.Kind, nostr.KindArticle)
+	}
+
+	for _, key := range []string{"d", "title"} {
+		if tagValue(e, key) == "" {
+			errorf("missing required %q tag", key)
+		}
+	}
+
+	for _, key := range []string{"summary", "image"} {
+		if tagValue(e, key) == "" {
+			warnf("missing recommended %q tag", key)
+		}
+	}
+
+	if published := tagValue(e, "published_at"); published == "" {
+		warnf("missing recommended \"published_at\" tag")
+	} else if _, err := strconv.ParseInt(published, 10, 64); err != nil {
+		errorf("\"published_at\" is not a unix timestamp: %q", published)
+	}
+
+	if e.Tags.GetFirst([]string{"t", ""}) == nil {
+		warnf("no \"t\" hashtags")
+	}
+
+	if strings.TrimSpace(e.Content) == "" {
+		errorf("content is empty")
+	}
+
+	if len(e.Content) > maxArticleSize {
+		errorf("content is %d bytes, larger than the %d byte limit", len(e.Content), maxArticleSize)
+	}
+
+	for _, m := range nostrReference.FindAllStringSubmatch(e.Content, -1) {
+		if _, _, err := nip19.Decode(m[1]); err != nil {
+			errorf("broken reference %q: %s", m[0], err)
+		}
+	}
+
+	problems = append(problems, lintMarkdown(e.Content)...)
+
+	return problems
+}
+
+// Catch the markdown mistakes that silently swallow the rest of an article.
+func lintMarkdown(content string) []Problem {
+
+	problems := []Problem{}
+
+	fence := ""
+	fenceLine := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxArticleSize)
+
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			marker := trimmed[:3]
+			if fence == "" {
+				fence, fenceLine = marker, n
+			} else if fence == marker {
+				fence = ""
+			}
+			continue
+		}
+
+		// Nothing inside a code block is interpreted as markdown
+		if fence != "" {
+			continue
+		}
+
+		if unclosedLink.MatchString(line) {
+			problems = append(problems, Problem{SeverityError, fmt.Sprintf("line %d: link is missing a closing parenthesis", n)})
+		}
+
+		if strings.Contains(strings.ToLower(line), "<script") {
+			problems = append(problems, Problem{SeverityWarning, fmt.Sprintf("line %d: script tags are stripped when rendered", n)})
+		}
+	}
+
+	// The scanner stops at a line longer than its buffer, so what follows is
+	// not checked
+	if err := scanner.Err(); err != nil {
+		msg := err.Error()
+		if errors.Is(err, bufio.ErrTooLong) {
+			msg = fmt.Sprintf("longer than %d bytes", maxArticleSize)
+		}
+		return append(problems, Problem{SeverityError, fmt.Sprintf("line %d: %s, the rest of the content is not checked", n+1, msg)})
+	}
+
+	if fence != "" {
+		problems = append(problems, Problem{SeverityError, fmt.Sprintf("line %d: code block is never closed", fenceLine)})
+	}
+
+	return problems
+}
+
+func tagValue(e *nostr.Event, key string) string {
+	if t := e.Tags.GetFirst([]string{key, ""}); t != nil {
+		return strings.TrimSpace(t.Value())
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package notezero
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestLintArticle(t *testing.T) {
+
+	complete := nostr.Tags{
+		{"d", "intro"},
+		{"title", "Intro"},
+		{"summary", "An introduction"},
+		{"image", "https://example.com/intro.png"},
+		{"published_at", "1700000000"},
+		{"t", "nostr"},
+	}
+
+	_, _, decodeErr := nip19.Decode("npub1broken")
+
+	// Complete tags, without the ones with the key
+	without := func(keys ...string) nostr.Tags {
+		tags := nostr.Tags{}
+		for _, t := range complete {
+			if !slices.Contains(keys, t.Key()) {
+				tags = append(tags, t)
+			}
+		}
+		return tags
+	}
+
+	tests := []struct {
+		name     string
+		event    nostr.Event
+		problems []Problem
+	}{
+		{
+			name:  "valid",
+			event: nostr.Event{Kind: nostr.KindArticle, Tags: complete, Content: "# Intro\n\nHello [world](https://example.com)."},
+		},
+		{
+			name:  "wrong kind",
+			event: nostr.Event{Kind: nostr.KindTextNote, Tags: complete, Content: "hello"},
+			problems: []Problem{
+				{SeverityError, "event is kind 1, not 30023"},
+			},
+		},
+		{
+			name:  "missing required tags",
+			event: nostr.Event{Kind: nostr.KindArticle, Tags: without("d", "title"), Content: "hello"},
+			problems: []Problem{
+				{SeverityError, `missing required "d" tag`},
+				{SeverityError, `missing required "title" tag`},
+			},
+		},
+		{
+			name:  "blank required tag",
+			event: nostr.Event{Kind: nostr.KindArticle, Tags: append(without("title"), nostr.Tag{"title", "  "}), Content: "hello"},
+			problems: []Problem{
+				{SeverityError, `missing required "title" tag`},
+			},
+		},
+		{
+			name:  "missing recommended tags",
+			event: nostr.Event{Kind: nostr.KindArticle, Tags: without("summary", "image", "published_at", "t"), Content: "hello"},
+			problems: []Problem{
+				{SeverityWarning, `missing recommended "summary" tag`},
+				{SeverityWarning, `missing recommended "image" tag`},
+				{SeverityWarning, `missing recommended "published_at" tag`},
+				{SeverityWarning, `no "t" hashtags`},
+			},
+		},
+		{
+			name:  "invalid published_at",
+			event: nostr.Event{Kind: nostr.KindArticle, Tags: append(without("published_at"), nostr.Tag{"published_at", "2024-01-01"}), Content: "hello"},
+			problems: []Problem{
+				{SeverityError, `"published_at" is not a unix timestamp: "2024-01-01"`},
+			},
+		},
+		{
+			name:  "empty content",
+			event: nostr.Event{Kind: nostr.KindArticle, Tags: complete, Content: " \n "},
+			problems: []Problem{
+				{SeverityError, "content is empty"},
+			},
+		},
+		{
+			name:  "oversized content",
+			event: nostr.Event{Kind: nostr.KindArticle, Tags: complete, Content: strings.Repeat("a\n", maxArticleSize/2+1)},
+			problems: []Problem{
+				{SeverityError, "content is 102402 bytes, larger than the 102400 byte limit"},
+			},
+		},
+		{
+			name:  "broken reference",
+			event: nostr.Event{Kind: nostr.KindArticle, Tags: complete, Content: "see nostr:npub1broken"},
+			problems: []Problem{
+				{SeverityError, `broken reference "nostr:npub1broken": ` + decodeErr.Error()},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := LintArticle(&tt.event)
+			if !slices.Equal(problems, tt.problems) {
+				t.Fatalf("got %v, want %v", problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestLintMarkdown(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		content  string
+		problems []Problem
+	}{
+		{
+			name:    "valid",
+			content: "[link](https://example.com)\n\n```\ncode\n```",
+		},
+		{
+			name:    "unclosed link",
+			content: "intro\n[link](https://example.com\nmore",
+			problems: []Problem{
+				{SeverityError, "line 2: link is missing a closing parenthesis"},
+			},
+		},
+		{
+			name:    "script tag",
+			content: "<SCRIPT>alert(1)</SCRIPT>",
+			problems: []Problem{
+				{SeverityWarning, "line 1: script tags are stripped when rendered"},
+			},
+		},
+		{
+			name:    "unclosed code block",
+			content: "intro\n~~~\ncode",
+			problems: []Problem{
+				{SeverityError, "line 2: code block is never closed"},
+			},
+		},
+		{
+			name:    "code block is not markdown",
+			content: "```\n[link](https://example.com\n<script>\n```",
+		},
+		{
+			name:    "other fence inside a code block",
+			content: "```\n~~~\n```",
+		},
+		{
+			name:    "line too long",
+			content: "intro\n" + strings.Repeat("a", maxArticleSize+1) + "\n[link](https://example.com",
+			problems: []Problem{
+				{SeverityError, fmt.Sprintf("line 2: longer than %d bytes, the rest of the content is not checked", maxArticleSize)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := lintMarkdown(tt.content)
+			if !slices.Equal(problems, tt.problems) {
+				t.Fatalf("got %v, want %v", problems, tt.problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+//go:build lmdb
+
+package lmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"time"
+
+	"github.com/PowerDNS/lmdb-go/lmdb"
+)
+
+// Key-value store next to the eventstore, in its own LMDB environment since
+// the one of the eventstore is not exported. Values are prefixed with their
+// expiry as a unix timestamp, zero when they never expire.
+type Cache struct {
+	env *lmdb.Env
+	dbi lmdb.DBI
+}
+
+func New(path string) (*Cache, error) {
+
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	env.SetMaxDBs(1)
+	env.SetMapSize(1 << 30)
+
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.Open(path, lmdb.NoTLS, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache{env: env}
+
+	err = env.Update(func(txn *lmdb.Txn) error {
+		c.dbi, err = txn.OpenDBI("cache", lmdb.Create)
+		return err
+	})
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Cache) Close() error {
+	return c.env.Close()
+}
+
+func expired(val []byte) bool {
+	exp := int64(binary.BigEndian.Uint64(val[:8]))
+	return exp != 0 && exp <= time.Now().Unix()
+}
+
+// The value of the key, false if it does not exist or expired.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+
+	var value []byte
+	found := false
+
+	err := c.env.View(func(txn *lmdb.Txn) error {
+		val, err := txn.Get(c.dbi, []byte(key))
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if expired(val) {
+			return nil
+		}
+		value = bytes.Clone(val[8:])
+		found = true
+		return nil
+	})
+
+	return value, found, err
+}
+
+// Set the value of the key, which expires after the ttl or never when the
+// ttl is zero.
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
+
+	val := make([]byte, 8, 8+len(value))
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(val, uint64(time.Now().Add(ttl).Unix()))
+	}
+	val = append(val, value...)
+
+	return c.env.Update(func(txn *lmdb.Txn) error {
+		return txn.Put(c.dbi, []byte(key), val, 0)
+	})
+}
+
+func (c *Cache) Delete(key string) error {
+	return c.env.Update(func(txn *lmdb.Txn) error {
+		err := txn.Del(c.dbi, []byte(key), nil)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+// All keys that start with the prefix, in order.
+func (c *Cache) Keys(prefix string) ([]string, error) {
+
+	keys := []string{}
+
+	err := c.env.View(func(txn *lmdb.Txn) error {
+		cur, err := txn.OpenCursor(c.dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		k, v, err := cur.Get([]byte(prefix), nil, lmdb.SetRange)
+		for ; err == nil; k, v, err = cur.Get(nil, nil, lmdb.Next) {
+			if !bytes.HasPrefix(k, []byte(prefix)) {
+				return nil
+			}
+			if !expired(v) {
+				keys = append(keys, string(k))
+			}
+		}
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+
+	return keys, err
+}
@@ -0,0 +1,3 @@
+// Key-value store for the LMDB eventstore. LMDB needs cgo and is only built
+// with the lmdb tag, go build -tags lmdb.
+package lmdb
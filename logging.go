@@ -27,10 +27,15 @@ func (s logging) RequestEvent(ctx context.Context, code string) (evt *nostr.Even
 	s.log.Info("requesting events", "service", "EventService")
 
 	defer func(start time.Time) {
+		// No event when the request failed, like for a deleted one
+		id := ""
+		if evt != nil {
+			id = evt.ID
+		}
 		s.log.Info(
 			"RequestEvent",
 			"code", code,
-			"id", evt.ID,
+			"id", id,
 			"err", err,
 			"took", time.Since(start),
 		)
@@ -47,7 +52,28 @@ func (s logging) AuthorArticles(ctx context.Context, npub string) ([]*nostr.Even
 	return s.next.AuthorArticles(ctx, npub)
 }
 
+func (s logging) TagArticles(ctx context.Context, tag string) ([]*nostr.Event, error) {
+
+	s.log.Info("requesting tagged articles", "tag", tag)
+
+	return s.next.TagArticles(ctx, tag)
+}
+
 func (s logging) ArticleHighlights(ctx context.Context, kind int, pubkey, identifier string) ([]*nostr.Event, error) {
 
 	return s.next.ArticleHighlights(ctx, kind, pubkey, identifier)
 }
+
+func (s logging) ArticleBacklinks(ctx context.Context, kind int, pubkey, identifier string) ([]*nostr.Event, error) {
+
+	s.log.Info("requesting backlinks", "kind", kind, "pubkey", pubkey, "identifier", identifier)
+
+	return s.next.ArticleBacklinks(ctx, kind, pubkey, identifier)
+}
+
+func (s logging) FetchEvents(ctx context.Context, filters nostr.Filters) ([]*nostr.Event, error) {
+
+	s.log.Info("fetching referenced events", "filterCount", len(filters))
+
+	return s.next.FetchEvents(ctx, filters)
+}
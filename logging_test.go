@@ -0,0 +1,29 @@
+package notezero
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+type failing struct {
+	EventService
+}
+
+func (failing) RequestEvent(context.Context, string) (*nostr.Event, error) {
+	return nil, errors.New("event not found")
+}
+
+func TestLoggingFailedRequest(t *testing.T) {
+
+	s := NewLogging(slog.New(slog.NewTextHandler(io.Discard, nil)), failing{})
+
+	_, err := s.RequestEvent(context.Background(), "naddr1")
+	if err == nil {
+		t.Fatal("want the error of the service")
+	}
+}
@@ -0,0 +1,193 @@
+package notezero
+
+import (
+	"container/list"
+	"expvar"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Hits, misses and evictions of the in-memory cache, served on /debug/vars.
+var memoryCacheStats = expvar.NewMap("memory_cache")
+
+// LRU cache in front of the eventstore and the renderer, bounded by the
+// approximate size of its values. A nil cache is valid and caches nothing.
+//
+// Every entry belongs to a group, usually the address of an article, so all
+// entries of an article can be dropped at once when a newer version arrives.
+type MemoryCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	ll       *list.List
+	items    map[string]*list.Element
+	groups   map[string]map[string]bool
+}
+
+type memoryEntry struct {
+	key   string
+	group string
+	value any
+	size  int64
+}
+
+func NewMemoryCache(maxMB int) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: int64(maxMB) * 1024 * 1024,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+		groups:   map[string]map[string]bool{},
+	}
+}
+
+func (c *MemoryCache) Get(key string) (any, bool) {
+
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		memoryCacheStats.Add("misses", 1)
+		return nil, false
+	}
+
+	memoryCacheStats.Add("hits", 1)
+	c.ll.MoveToFront(el)
+
+	return el.Value.(*memoryEntry).value, true
+}
+
+// Values larger than the whole cache are not stored.
+func (c *MemoryCache) Put(key, group string, value any, size int64) {
+
+	if c == nil || size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	el := c.ll.PushFront(&memoryEntry{key: key, group: group, value: value, size: size})
+	c.items[key] = el
+	c.bytes += size
+
+	if c.groups[group] == nil {
+		c.groups[group] = map[string]bool{}
+	}
+	c.groups[group][key] = true
+
+	for c.bytes > c.maxBytes {
+		c.remove(c.ll.Back())
+		memoryCacheStats.Add("evictions", 1)
+	}
+
+	c.updateSize()
+}
+
+func (c *MemoryCache) Delete(key string) {
+
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+		c.updateSize()
+	}
+}
+
+// Drop every entry of the group.
+func (c *MemoryCache) Invalidate(group string) {
+
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.groups[group] {
+		c.remove(c.items[key])
+	}
+	c.updateSize()
+}
+
+func (c *MemoryCache) remove(el *list.Element) {
+
+	entry := el.Value.(*memoryEntry)
+
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+
+	delete(c.groups[entry.group], entry.key)
+	if len(c.groups[entry.group]) == 0 {
+		delete(c.groups, entry.group)
+	}
+}
+
+func (c *MemoryCache) updateSize() {
+	bytes := new(expvar.Int)
+	bytes.Set(c.bytes)
+	memoryCacheStats.Set("bytes", bytes)
+
+	entries := new(expvar.Int)
+	entries.Set(int64(c.ll.Len()))
+	memoryCacheStats.Set("entries", entries)
+}
+
+// Rough size of a decoded event in memory.
+func eventSize(e *nostr.Event) int64 {
+	size := 256 + len(e.Content)
+	for _, t := range e.Tags {
+		for _, v := range t {
+			size += len(v) + 16
+		}
+	}
+	return int64(size)
+}
+
+func eventCacheKey(key string) string {
+	return "event:" + key
+}
+
+// Remember the event by id and, for replaceable events, by address.
+func (c *MemoryCache) putEvent(e *nostr.Event) {
+	group := replaceableKey(e)
+	if group == "" {
+		group = e.ID
+	}
+	c.Put(eventCacheKey(e.ID), group, e, eventSize(e))
+	if group != e.ID {
+		c.Put(eventCacheKey(group), group, e, eventSize(e))
+	}
+}
+
+func (c *MemoryCache) getEvent(key string) (*nostr.Event, bool) {
+	v, ok := c.Get(eventCacheKey(key))
+	if !ok {
+		return nil, false
+	}
+	return v.(*nostr.Event), true
+}
+
+// Forget the event and everything derived from it, like its rendered HTML.
+func (c *MemoryCache) forgetEvent(e *nostr.Event) {
+	if group := replaceableKey(e); group != "" {
+		c.Invalidate(group)
+	}
+	c.Invalidate(e.ID)
+	c.Delete(eventCacheKey(e.ID))
+}
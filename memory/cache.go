@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key-value store that lives as long as the process, for the in-memory
+// eventstore, tests and the CLI.
+type Cache struct {
+	mu    sync.RWMutex
+	items map[string]item
+}
+
+type item struct {
+	value   []byte
+	expires time.Time
+}
+
+func (i item) expired() bool {
+	return !i.expires.IsZero() && time.Now().After(i.expires)
+}
+
+func New() *Cache {
+	return &Cache{
+		items: map[string]item{},
+	}
+}
+
+// The value of the key, false if it does not exist or expired.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, ok := c.items[key]
+	if !ok || i.expired() {
+		return nil, false, nil
+	}
+
+	return slices.Clone(i.value), true, nil
+}
+
+// Set the value of the key, which expires after the ttl or never when the
+// ttl is zero.
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := item{value: slices.Clone(value)}
+	if ttl > 0 {
+		i.expires = time.Now().Add(ttl)
+	}
+	c.items[key] = i
+
+	return nil
+}
+
+func (c *Cache) Delete(key string) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+
+	return nil
+}
+
+// All keys that start with the prefix, in order. Expired keys are removed
+// on the way.
+func (c *Cache) Keys(prefix string) ([]string, error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := []string{}
+	for k, i := range c.items {
+		if i.expired() {
+			delete(c.items, k)
+			continue
+		}
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	return keys, nil
+}
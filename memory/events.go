@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/fiatjaf/eventstore/slicestore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ eventstore.Store = (*Events)(nil)
+
+// Slice eventstore that is safe for concurrent use, since pages are
+// refreshed in the background while others are read.
+type Events struct {
+	mu    sync.RWMutex
+	slice slicestore.SliceStore
+}
+
+func NewEvents() *Events {
+	e := &Events{}
+	e.slice.Init()
+	return e
+}
+
+func (e *Events) Init() error {
+	return nil
+}
+
+func (e *Events) Close() {}
+
+// The matches are collected under the lock, since the slice store reads its
+// slice after returning the channel.
+func (e *Events) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	src, err := e.slice.QueryEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*nostr.Event
+	for evt := range src {
+		events = append(events, evt)
+	}
+
+	ch := make(chan *nostr.Event, len(events))
+	for _, evt := range events {
+		ch <- evt
+	}
+	close(ch)
+
+	return ch, nil
+}
+
+func (e *Events) CountEvents(ctx context.Context, filter nostr.Filter) (int64, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.slice.CountEvents(ctx, filter)
+}
+
+func (e *Events) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.slice.SaveEvent(ctx, evt)
+}
+
+func (e *Events) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.slice.DeleteEvent(ctx, evt)
+}
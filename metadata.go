@@ -43,6 +43,7 @@ func ParseMetadata(e nostr.Event) (*ProfileMetadata, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse metadata from event %s: %w", e.ID, err)
 	}
+	profile.PubKey = e.PubKey
 
 	return &profile, nil
 }
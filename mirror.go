@@ -0,0 +1,212 @@
+package notezero
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Events mirrored for pinned authors, served on /debug/vars.
+var mirrorStats = expvar.NewMap("mirror")
+
+const (
+	// Events can reach relays some time after they were created, so a
+	// catch-up starts this long before the newest event seen.
+	mirrorOverlap = time.Hour
+	// The first catch-up of an author requests everything, which takes
+	// longer than the usual relay timeout.
+	mirrorTimeout = 30 * time.Second
+)
+
+// Profiles, relay lists, articles and the deletions of them.
+var mirroredKinds = []int{
+	nostr.KindProfileMetadata,
+	nostr.KindDeletion,
+	nostr.KindRelayListMetadata,
+	nostr.KindArticle,
+}
+
+// What is mirrored for an author: the events they publish, and the
+// highlights of their articles, which tag them with "p".
+func mirrorFilters(pubkey string) map[string]nostr.Filter {
+	return map[string]nostr.Filter{
+		"authored": {
+			Kinds:   mirroredKinds,
+			Authors: []string{pubkey},
+		},
+		"highlights": {
+			Kinds: []int{9802},
+			Tags:  nostr.TagMap{"p": []string{pubkey}},
+		},
+	}
+}
+
+// Request what the pinned authors published since the last catch-up, and
+// return how many events were received.
+//  1. Each stream starts at the newest event of the previous catch-up, so
+//     gaps in the live subscription are filled
+//  2. The highlights of their articles are marked as synced, so the article
+//     pages read them from the store instead of waiting for the relays
+func (s eventService) CatchUp(ctx context.Context, pubkeys []string) (int, error) {
+
+	count := 0
+
+	for _, pubkey := range pubkeys {
+		for stream, filter := range mirrorFilters(pubkey) {
+
+			key := cacheKey(nsMirror, pubkey, stream)
+
+			var newest nostr.Timestamp
+			found, err := getJSON(s.cache, key, &newest)
+			if err != nil {
+				return count, err
+			}
+			if found {
+				since := newest - nostr.Timestamp(mirrorOverlap.Seconds())
+				filter.Since = &since
+			}
+
+			n, latest, err := s.mirror(ctx, filter)
+			count += n
+			if err != nil {
+				return count, err
+			}
+
+			if latest > newest {
+				err := setJSON(s.cache, key, latest, 0)
+				if err != nil {
+					return count, err
+				}
+			}
+		}
+
+		err := s.markHighlightsSynced(ctx, pubkey)
+		if err != nil {
+			return count, err
+		}
+	}
+
+	mirrorStats.Add("catch_ups", 1)
+	mirrorStats.Add("caught_up_events", int64(count))
+
+	return count, nil
+}
+
+// Save the events of the filter that the relays have, and return how many
+// and the newest created_at.
+func (s eventService) mirror(ctx context.Context, filter nostr.Filter) (int, nostr.Timestamp, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, mirrorTimeout)
+	defer cancel()
+
+	count := 0
+	var latest nostr.Timestamp
+
+	for ie := range s.subscribe(ctx, nostr.Filters{filter}) {
+		err := s.save(ctx, ie.Event)
+		if err != nil {
+			return count, latest, err
+		}
+		count++
+		if ie.Event.CreatedAt > latest {
+			latest = ie.Event.CreatedAt
+		}
+	}
+
+	return count, latest, nil
+}
+
+func (s eventService) markHighlightsSynced(ctx context.Context, pubkey string) error {
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	articles, err := wdb.QuerySync(ctx, nostr.Filter{
+		Kinds:   []int{nostr.KindArticle},
+		Authors: []string{pubkey},
+		Limit:   500,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range articles {
+		err := s.setLastSync(cacheKey(nsHighlights, strconv.Itoa(e.Kind), e.PubKey, e.Tags.GetD()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Save the events of the pinned authors as relays receive them, until the
+// context is done. Relays that drop the connection are subscribed to again,
+// and CatchUp fills what was missed in between.
+func (s eventService) MirrorLive(ctx context.Context, pubkeys []string) {
+
+	if len(pubkeys) == 0 {
+		return
+	}
+
+	now := nostr.Now()
+
+	filters := nostr.Filters{
+		{
+			Kinds:   mirroredKinds,
+			Authors: pubkeys,
+			Since:   &now,
+		},
+		{
+			Kinds: []int{9802},
+			Tags:  nostr.TagMap{"p": pubkeys},
+			Since: &now,
+		},
+	}
+
+	pool := nostr.NewSimplePool(ctx)
+
+	// One subscription per relay, each with its own copy of the filters, since
+	// the pool moves their since forward when it reconnects, while accept
+	// reads them. The relays of one subscription would also share its state.
+	events := make(chan nostr.IncomingEvent)
+
+	var wg sync.WaitGroup
+	for _, url := range s.relays {
+		subFilters := make(nostr.Filters, len(filters))
+		for i, f := range filters {
+			subFilters[i] = f.Clone()
+		}
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			for ie := range pool.SubMany(ctx, []string{url}, subFilters) {
+				select {
+				case events <- ie:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(url)
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	// save skips the events another relay sent already
+	for ie := range events {
+		if !s.accept(ie.Relay.URL, ie.Event, filters) {
+			continue
+		}
+		err := s.save(ctx, ie.Event)
+		if err != nil {
+			continue
+		}
+		mirrorStats.Add("live_events", 1)
+	}
+}
@@ -0,0 +1,54 @@
+package notezero
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestMirrorLiveReconnects(t *testing.T) {
+
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+
+	relay := newTestRelay(t)
+	other := newTestRelay(t)
+
+	s := newTestService(t)
+	s.relays = []string{relay.URL, other.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.MirrorLive(ctx, []string{pk})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	stored := func(e *nostr.Event) func() bool {
+		return func() bool {
+			events, _ := eventstore.RelayWrapper{Store: s.db}.QuerySync(ctx, nostr.Filter{IDs: []string{e.ID}})
+			return len(events) == 1
+		}
+	}
+
+	waitFor(t, func() bool { return len(relay.requests()) == 1 && len(other.requests()) == 1 })
+
+	first := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "first"}}})
+	relay.publish(first)
+	waitFor(t, stored(first))
+
+	// The pool subscribes again, with the since of the filters moved forward,
+	// while the events of the other relay are checked against them
+	relay.drop()
+	waitFor(t, func() bool { return len(relay.requests()) == 2 })
+
+	second := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "second"}}})
+	other.publish(second)
+	waitFor(t, stored(second))
+}
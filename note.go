@@ -1,8 +1,11 @@
 package notezero
 
 import (
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/dextryz/notezero/render"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
 )
@@ -10,6 +13,8 @@ import (
 type EnhancedEvent struct {
 	*nostr.Event
 	Relays []string
+	// Computed when first needed, unless attached by MemoryCache.withStats
+	stats *noteStats
 }
 
 func (s EnhancedEvent) Title() string {
@@ -22,6 +27,60 @@ func (s EnhancedEvent) Title() string {
 	return title
 }
 
+func (s EnhancedEvent) Summary() string {
+	return tagValue(s.Event, "summary")
+}
+
+// Summary of the article, or the start of the content if the author left it out.
+func (s EnhancedEvent) Excerpt() string {
+	if summary := s.Summary(); summary != "" {
+		return summary
+	}
+	return render.Excerpt(s.Content, excerptLength)
+}
+
+// NIP-84 comment the highlighter added to the highlighted text.
+func (s EnhancedEvent) Comment() string {
+	return tagValue(s.Event, "comment")
+}
+
+// NIP-36 content-warning tag, with an optional reason.
+func (s EnhancedEvent) IsSensitive() bool {
+	return s.Tags.GetFirst([]string{"content-warning"}) != nil
+}
+
+func (s EnhancedEvent) ContentWarning() string {
+	if reason := tagValue(s.Event, "content-warning"); reason != "" {
+		return "Content warning: " + reason
+	}
+	return "Content warning"
+}
+
+func filterSensitive(events []EnhancedEvent) []EnhancedEvent {
+	filtered := []EnhancedEvent{}
+	for _, e := range events {
+		if !e.IsSensitive() {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+func (s EnhancedEvent) Image() string {
+	return tagValue(s.Event, "image")
+}
+
+// NIP-23 published_at stays the same when an article is edited, while
+// created_at is the time of the latest edit.
+func (s EnhancedEvent) PublishedAt() nostr.Timestamp {
+	if v := tagValue(s.Event, "published_at"); v != "" {
+		if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return nostr.Timestamp(ts)
+		}
+	}
+	return s.CreatedAt
+}
+
 func (s EnhancedEvent) HashTags() []string {
 	tags := []string{}
 	for _, t := range s.Tags {
@@ -85,3 +144,21 @@ func (s EnhancedEvent) CreatedAtStr() string {
 func (s EnhancedEvent) ModifiedAtStr() string {
 	return time.Unix(int64(s.Event.CreatedAt), 0).Format("2006-01-02T15:04:05Z07:00")
 }
+
+func (s EnhancedEvent) PublishedAtStr() string {
+	return time.Unix(int64(s.PublishedAt()), 0).Format("2006-01-02 15:04:05")
+}
+
+// Only true if the article was edited after it was published.
+func (s EnhancedEvent) IsUpdated() bool {
+	return s.CreatedAt > s.PublishedAt()
+}
+
+const excerptLength = 200
+
+// Newest published articles first.
+func sortByPublishedAt(events []*nostr.Event) {
+	slices.SortFunc(events, func(a, b *nostr.Event) int {
+		return int(EnhancedEvent{Event: b}.PublishedAt() - EnhancedEvent{Event: a}.PublishedAt())
+	})
+}
@@ -0,0 +1,119 @@
+package notezero
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abadojack/whatlanggo"
+	"github.com/dextryz/notezero/render"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Average adult silent reading speed.
+const wordsPerMinute = 200
+
+// Word count and language of an article. Both need its plain text, and the
+// language detection is slow, so they are computed once per event.
+type noteStats struct {
+	words    int
+	language string
+}
+
+func computeStats(e *nostr.Event) *noteStats {
+
+	text := render.PlainText(e.Content)
+
+	stats := &noteStats{
+		words:    len(strings.Fields(text)),
+		language: labeledLanguage(e),
+	}
+
+	if stats.language == "" {
+		if info := whatlanggo.Detect(text); info.IsReliable() {
+			stats.language = info.Lang.Iso6391()
+		}
+	}
+
+	return stats
+}
+
+// An NIP-32 language label from the author is preferred over detection.
+func labeledLanguage(e *nostr.Event) string {
+	for _, t := range e.Tags {
+		if len(t) >= 3 && t[0] == "l" && strings.EqualFold(t[2], "ISO-639-1") {
+			return strings.ToLower(t[1])
+		}
+	}
+	return ""
+}
+
+func statsCacheKey(id string) string {
+	return "stats:" + id
+}
+
+// Stats of the event, computed once while it stays in the cache. An event
+// id always has the same content, so they never go stale.
+func (c *MemoryCache) noteStats(e *nostr.Event) *noteStats {
+
+	if v, ok := c.Get(statsCacheKey(e.ID)); ok {
+		return v.(*noteStats)
+	}
+
+	stats := computeStats(e)
+	c.Put(statsCacheKey(e.ID), e.ID, stats, 64)
+
+	return stats
+}
+
+// Attach the stats to the notes, so filtering and rendering share them.
+func (c *MemoryCache) withStats(notes []EnhancedEvent) {
+	for i := range notes {
+		notes[i].stats = c.noteStats(notes[i].Event)
+	}
+}
+
+func (s EnhancedEvent) noteStats() *noteStats {
+	if s.stats != nil {
+		return s.stats
+	}
+	return computeStats(s.Event)
+}
+
+func (s EnhancedEvent) WordCount() int {
+	return s.noteStats().words
+}
+
+// Estimated minutes to read the article, at least one.
+func (s EnhancedEvent) ReadingTime() int {
+	return readingTime(s.WordCount())
+}
+
+func readingTime(words int) int {
+	return max((words+wordsPerMinute-1)/wordsPerMinute, 1)
+}
+
+// ISO 639-1 code of the language the article is written in, or an empty string
+// if unknown. An NIP-32 label from the author is preferred over detection.
+func (s EnhancedEvent) Language() string {
+	return s.noteStats().language
+}
+
+func (s EnhancedEvent) Stats() string {
+	ns := s.noteStats()
+	stats := fmt.Sprintf("%d min read · %d words", readingTime(ns.words), ns.words)
+	if ns.language != "" {
+		stats += " · " + ns.language
+	}
+	return stats
+}
+
+// Only keep the events written in the given language.
+func filterLanguage(events []EnhancedEvent, lang string) []EnhancedEvent {
+	filtered := []EnhancedEvent{}
+	for _, e := range events {
+		if strings.EqualFold(e.Language(), lang) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
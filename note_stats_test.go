@@ -0,0 +1,75 @@
+package notezero
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const english = "The quick brown fox jumps over the lazy dog, and then it runs back into the forest where it lives with its family."
+
+func TestNoteStats(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		event nostr.Event
+		stats string
+	}{
+		{
+			name:  "detected language",
+			event: nostr.Event{Content: "# Title\n\n" + english},
+			stats: "1 min read · 24 words · en",
+		},
+		{
+			name:  "labeled language",
+			event: nostr.Event{Content: english, Tags: nostr.Tags{{"L", "ISO-639-1"}, {"l", "DE", "ISO-639-1"}}},
+			stats: "1 min read · 23 words · de",
+		},
+		{
+			name:  "unknown language",
+			event: nostr.Event{Content: "ok"},
+			stats: "1 min read · 1 words",
+		},
+		{
+			name:  "reading time",
+			event: nostr.Event{Content: strings.Repeat("word ", 401)},
+			stats: "3 min read · 401 words",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (EnhancedEvent{Event: &tt.event}).Stats(); got != tt.stats {
+				t.Fatalf("got %q, want %q", got, tt.stats)
+			}
+		})
+	}
+}
+
+func TestNoteStatsAreMemoized(t *testing.T) {
+
+	mem := NewMemoryCache(1)
+
+	notes := []EnhancedEvent{
+		{Event: &nostr.Event{ID: "a", Content: english}},
+		{Event: &nostr.Event{ID: "b", Content: english, Tags: nostr.Tags{{"l", "de", "ISO-639-1"}}}},
+	}
+	mem.withStats(notes)
+
+	if notes[0].stats != mem.noteStats(notes[0].Event) {
+		t.Fatal("stats were computed again")
+	}
+
+	filtered := filterLanguage(notes, "EN")
+	if len(filtered) != 1 || filtered[0].ID != "a" || filtered[0].stats != notes[0].stats {
+		t.Fatalf("got %v, want the english note with its stats", filtered)
+	}
+
+	// Without a cache the stats are still computed once per request
+	var none *MemoryCache
+	none.withStats(notes)
+	if notes[0].stats == nil || notes[0].stats.language != "en" {
+		t.Fatalf("got %+v", notes[0].stats)
+	}
+}
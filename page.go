@@ -4,6 +4,7 @@ import (
 	"html/template"
 
 	"github.com/a-h/templ"
+	"github.com/dextryz/notezero/render"
 )
 
 type DetailsParams struct {
@@ -24,7 +25,10 @@ type BaseEventPageParams struct {
 }
 
 type ListArticleParams struct {
-	Notes []EnhancedEvent
+	Notes   []EnhancedEvent
+	Profile ProfileMetadata // Only set when listing the articles of an author
+	About   template.HTML
+	Tag     string // Only set when listing the articles with a hashtag
 }
 
 type SpinnerParams struct {
@@ -36,4 +40,29 @@ type ArticleParams struct {
 	Metadata ProfileMetadata
 	Details  DetailsParams
 	Content  template.HTML // Highlights are encoded into the content
+	Outline  []render.Heading
+	// Highlights are also listed below the content, along with their comments
+	Highlights []HighlightParams
+}
+
+type HighlightParams struct {
+	Event   EnhancedEvent
+	Comment template.HTML
+}
+
+// Short articles are easy enough to navigate without a table of contents.
+const minOutlineHeadings = 4
+
+func (s ArticleParams) ShowOutline() bool {
+	return len(s.Outline) >= minOutlineHeadings
+}
+
+type LintParams struct {
+	Npub         string
+	ArticleCount int
+	Reports      []ArticleReport
+}
+
+type BacklinksParams struct {
+	Notes []EnhancedEvent
 }
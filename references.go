@@ -0,0 +1,96 @@
+package notezero
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dextryz/notezero/render"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Address of a parameterized replaceable event, as used in "a" tags.
+func eventAddress(e *nostr.Event) string {
+	return fmt.Sprintf("%d:%s:%s", e.Kind, e.PubKey, e.Tags.GetD())
+}
+
+func isAddressable(e *nostr.Event) bool {
+	return e.Kind >= 30000 && e.Kind < 40000
+}
+
+// Addresses of the events that e refers to.
+// 1. "a" tags, used by articles and notes that quote an article
+// 2. nostr:naddr links and mentions in the content
+func referencedAddresses(e *nostr.Event) []string {
+
+	seen := map[string]bool{}
+	addresses := []string{}
+
+	add := func(address string) {
+		if seen[address] || (isAddressable(e) && address == eventAddress(e)) {
+			return
+		}
+		seen[address] = true
+		addresses = append(addresses, address)
+	}
+
+	for _, t := range e.Tags {
+		if t.Key() == "a" && t.Value() != "" {
+			add(t.Value())
+		}
+	}
+
+	for _, code := range render.References(e.Content) {
+		prefix, data, err := nip19.Decode(code)
+		if err != nil || prefix != "naddr" {
+			continue
+		}
+		v := data.(nostr.EntityPointer)
+		add(fmt.Sprintf("%d:%s:%s", v.Kind, v.PublicKey, v.Identifier))
+	}
+
+	return addresses
+}
+
+// Only articles and notes are shown as backlinks, not highlights.
+var backlinkKinds = []int{nostr.KindTextNote, nostr.KindArticle}
+
+func backlinkKey(address, id string) string {
+	return cacheKey(nsReferences, append(addressParts(address), id)...)
+}
+
+// Record which events are referred to by e, so the article can list it.
+func (s eventService) indexReferences(e *nostr.Event) error {
+	if !slices.Contains(backlinkKinds, e.Kind) {
+		return nil
+	}
+	for _, address := range referencedAddresses(e) {
+		err := s.cache.Set(backlinkKey(address, e.ID), nil, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ids of the events that refer to the address.
+func (s eventService) backlinks(address string) ([]string, error) {
+
+	prefix := backlinkKey(address, "")
+
+	keys, err := s.cache.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, k := range keys {
+		// Anything else would fail the whole query of the backlinks
+		if id := strings.TrimPrefix(k, prefix); nostr.IsValid32ByteHex(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,78 @@
+package notezero
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestBacklinkKey(t *testing.T) {
+
+	id := "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"
+
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"30023:pk:foo", "refs:30023:pk:foo:" + id},
+		{"30023:pk:foo:bar", "refs:30023:pk:foo%3Abar:" + id},
+		{"30023:pk:100%", "refs:30023:pk:100%25:" + id},
+		{"30023:pk:", "refs:30023:pk::" + id},
+	}
+
+	for _, tt := range tests {
+		if got := backlinkKey(tt.address, id); got != tt.want {
+			t.Errorf("backlinkKey(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+
+	s := newTestService(t)
+	sk := nostr.GeneratePrivateKey()
+
+	foo := "30023:pk:foo"
+	fooBar := "30023:pk:foo:bar"
+
+	toFoo := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, Tags: nostr.Tags{{"a", foo}}})
+	toFooBar := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "x"}, {"a", fooBar}}})
+	toBoth := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, Tags: nostr.Tags{{"a", foo}, {"a", fooBar}}})
+	// Highlights are not backlinks
+	highlight := signed(t, sk, nostr.Event{Kind: 9802, Tags: nostr.Tags{{"a", foo}}})
+
+	for _, e := range []*nostr.Event{toFoo, toFooBar, toBoth, highlight} {
+		err := s.indexReferences(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Keys that are not event ids are skipped
+	err := s.cache.Set("refs:30023:pk:foo:baz:"+toFoo.ID, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		address string
+		want    []string
+	}{
+		{foo, []string{toFoo.ID, toBoth.ID}},
+		{fooBar, []string{toFooBar.ID, toBoth.ID}},
+		{"30023:pk:fo", []string{}},
+	}
+
+	for _, tt := range tests {
+		got, err := s.backlinks(tt.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+		slices.Sort(tt.want)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("backlinks(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
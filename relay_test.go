@@ -0,0 +1,128 @@
+package notezero
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Relay that serves its events for REQ messages, and sends events added later
+// to the open subscriptions.
+type testRelay struct {
+	URL string
+
+	mu      sync.Mutex
+	events  []*nostr.Event
+	subs    []func(*nostr.Event)
+	filters []nostr.Filters
+	conns   []net.Conn
+}
+
+func newTestRelay(t *testing.T, events ...*nostr.Event) *testRelay {
+	t.Helper()
+	r := &testRelay{events: events}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	r.URL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	return r
+}
+
+func (r *testRelay) publish(e *nostr.Event) {
+	r.mu.Lock()
+	r.events = append(r.events, e)
+	subs := slices.Clone(r.subs)
+	r.mu.Unlock()
+	for _, send := range subs {
+		send(e)
+	}
+}
+
+// Close the open connections, like a relay that restarts.
+func (r *testRelay) drop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, conn := range r.conns {
+		conn.Close()
+	}
+	r.conns = nil
+	r.subs = nil
+}
+
+// Filters of every REQ received, in order.
+func (r *testRelay) requests() []nostr.Filters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.filters)
+}
+
+func (r *testRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	conn, _, _, err := ws.UpgradeHTTP(req, w)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r.mu.Lock()
+	r.conns = append(r.conns, conn)
+	r.mu.Unlock()
+
+	var mu sync.Mutex
+	send := func(v ...any) {
+		b, _ := json.Marshal(v)
+		mu.Lock()
+		defer mu.Unlock()
+		wsutil.WriteServerText(conn, b)
+	}
+
+	for {
+		msg, err := wsutil.ReadClientText(conn)
+		if err != nil {
+			return
+		}
+
+		var raw []json.RawMessage
+		if json.Unmarshal(msg, &raw) != nil || len(raw) < 2 {
+			continue
+		}
+		var typ, id string
+		json.Unmarshal(raw[0], &typ)
+		json.Unmarshal(raw[1], &id)
+		if typ != "REQ" {
+			continue
+		}
+
+		filters := nostr.Filters{}
+		for _, f := range raw[2:] {
+			var filter nostr.Filter
+			json.Unmarshal(f, &filter)
+			filters = append(filters, filter)
+		}
+
+		r.mu.Lock()
+		r.filters = append(r.filters, filters)
+		stored := slices.Clone(r.events)
+		r.subs = append(r.subs, func(e *nostr.Event) {
+			if filters.Match(e) {
+				send("EVENT", id, e)
+			}
+		})
+		r.mu.Unlock()
+
+		for _, e := range stored {
+			if filters.Match(e) {
+				send("EVENT", id, e)
+			}
+		}
+		send("EOSE", id)
+	}
+}
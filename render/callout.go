@@ -0,0 +1,153 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// > [!NOTE] Optional title
+// > Body of the callout
+//
+// The syntax used by GitHub and Obsidian. The +/- fold markers of Obsidian
+// are accepted but ignored.
+var calloutMarker = regexp.MustCompile(`^\[!([a-zA-Z]+)\][+-]?[ \t]*([^\n]*)\n?`)
+
+// Obsidian aliases are mapped onto the five GitHub kinds, so the stylesheet
+// only needs to know about those.
+var calloutKinds = map[string]string{
+	"note":      "note",
+	"info":      "note",
+	"abstract":  "note",
+	"summary":   "note",
+	"quote":     "note",
+	"tip":       "tip",
+	"hint":      "tip",
+	"success":   "tip",
+	"important": "important",
+	"question":  "important",
+	"todo":      "important",
+	"warning":   "warning",
+	"attention": "warning",
+	"caution":   "caution",
+	"danger":    "caution",
+	"error":     "caution",
+	"bug":       "caution",
+	"failure":   "caution",
+}
+
+// A blockquote that starts with a callout marker.
+type Callout struct {
+	ast.Container
+	Kind  string
+	Title string
+}
+
+// Swap every blockquote starting with a callout marker for a Callout, keeping
+// the rest of the blockquote as its body.
+func parseCallouts(doc ast.Node) {
+
+	quotes := []*ast.BlockQuote{}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if q, ok := node.(*ast.BlockQuote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return ast.GoToNext
+	})
+
+	for _, q := range quotes {
+		nodes := []ast.Node{}
+		for _, part := range splitCallouts(q) {
+			nodes = append(nodes, callout(part))
+		}
+		replaceNode(q, nodes)
+	}
+}
+
+// The parser merges quotes that are only separated by an empty line, so two
+// callouts in a row end up in the same blockquote. Split them up again.
+func splitCallouts(q *ast.BlockQuote) []*ast.BlockQuote {
+
+	parts := []*ast.BlockQuote{{}}
+
+	for i, child := range q.Children {
+		if i > 0 && calloutMatch(child) != nil {
+			parts = append(parts, &ast.BlockQuote{})
+		}
+		last := parts[len(parts)-1]
+		child.SetParent(last)
+		last.Children = append(last.Children, child)
+	}
+
+	return parts
+}
+
+// The marker at the start of a paragraph, if there is one.
+func calloutMatch(node ast.Node) [][]byte {
+	p, ok := node.(*ast.Paragraph)
+	if !ok || len(p.Children) == 0 {
+		return nil
+	}
+	text, ok := p.Children[0].(*ast.Text)
+	if !ok {
+		return nil
+	}
+	return calloutMarker.FindSubmatch(text.Literal)
+}
+
+// The blockquote as a Callout, or unchanged if it does not start with a marker.
+func callout(q *ast.BlockQuote) ast.Node {
+
+	if len(q.Children) == 0 {
+		return q
+	}
+
+	m := calloutMatch(q.Children[0])
+	if m == nil {
+		return q
+	}
+
+	name := strings.ToLower(string(m[1]))
+	kind, ok := calloutKinds[name]
+	if !ok {
+		kind = "note"
+	}
+
+	title := strings.TrimSpace(string(m[2]))
+	if title == "" {
+		title = strings.ToUpper(name[:1]) + name[1:]
+	}
+
+	// Drop the marker, and the paragraph if the marker was all there was
+	p := q.Children[0].(*ast.Paragraph)
+	text := p.Children[0].(*ast.Text)
+	text.Literal = text.Literal[len(m[0]):]
+	if len(text.Literal) == 0 {
+		p.Children = p.Children[1:]
+	}
+	children := q.Children
+	if len(p.Children) == 0 {
+		children = children[1:]
+	}
+
+	c := &Callout{Kind: kind, Title: title}
+	for _, child := range children {
+		child.SetParent(c)
+	}
+	c.Children = children
+
+	return c
+}
+
+func renderCallout(w io.Writer, c *Callout, entering bool) {
+	if !entering {
+		io.WriteString(w, "</div>\n")
+		return
+	}
+	fmt.Fprintf(w, `<div class="callout callout-%s"><p class="callout-title">%s</p>`, c.Kind, template.HTMLEscapeString(c.Title))
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Highlight a fenced code block using the language in its info string.
+//
+// Tokens are only tagged with classes, the colours are defined in
+// static/chroma.css. The copy button is wired up by static/code.js.
+func (d document) renderCodeBlock(w io.Writer, block *ast.CodeBlock) bool {
+
+	lang := strings.ToLower(strings.TrimSpace(strings.SplitN(string(block.Info), " ", 2)[0]))
+
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, string(block.Literal))
+	if err != nil {
+		return false
+	}
+
+	formatter := chromahtml.New(
+		chromahtml.WithClasses(true),
+		chromahtml.WithLineNumbers(d.opts.CodeLineNumbers),
+	)
+
+	var code strings.Builder
+	err = formatter.Format(&code, styles.Fallback, iterator)
+	if err != nil {
+		return false
+	}
+
+	class := "code-block"
+	if lang != "" {
+		class += " language-" + template.HTMLEscapeString(lang)
+	}
+
+	fmt.Fprintf(w, `<div class="%s"><button class="copy-code" type="button">Copy</button>%s</div>`, class, code.String())
+
+	return true
+}
@@ -0,0 +1,143 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// A site whose page urls can be shown as an embedded player.
+type provider struct {
+	page *regexp.Regexp
+	// Every embed url starts with the prefix, which the sanitizer enforces.
+	prefix string
+	embed  func(m []string) string
+}
+
+var providers = map[string]provider{
+	"youtube": {
+		page:   regexp.MustCompile(`^https?://(?:www\.|m\.)?(?:youtube\.com/watch\?(?:.*&)?v=|youtube\.com/(?:shorts|live)/|youtu\.be/)([A-Za-z0-9_-]{11})`),
+		prefix: "https://www.youtube-nocookie.com/embed/",
+		embed: func(m []string) string {
+			return "https://www.youtube-nocookie.com/embed/" + m[1]
+		},
+	},
+	"vimeo": {
+		page:   regexp.MustCompile(`^https?://(?:www\.)?vimeo\.com/([0-9]+)`),
+		prefix: "https://player.vimeo.com/video/",
+		embed: func(m []string) string {
+			return "https://player.vimeo.com/video/" + m[1]
+		},
+	},
+	"spotify": {
+		page:   regexp.MustCompile(`^https?://open\.spotify\.com/(track|album|playlist|episode|show)/([A-Za-z0-9]+)`),
+		prefix: "https://open.spotify.com/embed/",
+		embed: func(m []string) string {
+			return "https://open.spotify.com/embed/" + m[1] + "/" + m[2]
+		},
+	},
+}
+
+// Names of the providers that can be passed as Options.EmbedProviders.
+func Providers() []string {
+	names := []string{}
+	for name := range providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+var mediaTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".m4a":  "audio/mp4",
+	".wav":  "audio/wav",
+}
+
+// A bare url is an autolink, where the text is the url itself.
+func bareURL(node ast.Node) string {
+	link, ok := node.(*ast.Link)
+	if !ok {
+		return ""
+	}
+	if dest := string(link.Destination); plainText(link) == dest {
+		return dest
+	}
+	return ""
+}
+
+// Media files become HTML5 players, and pages of allowed providers become
+// sandboxed iframes. Empty if the url is neither.
+func (d document) embed(href string) string {
+
+	u, err := url.Parse(href)
+	if href == "" || err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return ""
+	}
+
+	if mime, ok := mediaTypes[strings.ToLower(path.Ext(u.Path))]; ok {
+		tag := strings.Split(mime, "/")[0]
+		return fmt.Sprintf(`<%s controls preload="metadata"><source src="%s" type="%s"></%s>`+"\n",
+			tag, template.HTMLEscapeString(href), mime, tag)
+	}
+
+	for _, name := range d.opts.EmbedProviders {
+		p, ok := providers[name]
+		if !ok {
+			continue
+		}
+		if m := p.page.FindStringSubmatch(href); m != nil {
+			return fmt.Sprintf(`<div class="embed embed-%s"><iframe src="%s" title="%s" sandbox="%s" allow="%s" allowfullscreen loading="lazy" referrerpolicy="strict-origin-when-cross-origin"></iframe></div>`+"\n",
+				name, template.HTMLEscapeString(p.embed(m)), name, iframeSandbox, iframeAllow)
+		}
+	}
+
+	return ""
+}
+
+const (
+	iframeSandbox = "allow-scripts allow-same-origin allow-presentation allow-popups"
+	iframeAllow   = "encrypted-media; fullscreen; picture-in-picture"
+)
+
+// The sanitizer removes the src of iframes that are not from an allowed
+// provider, but keeps the element itself when it has other attributes.
+// Iframes without the sandbox, like the ones written as html by the author,
+// are stripped as well.
+var iframeTag = regexp.MustCompile(`(?s)<iframe\b([^>]*)>.*?</iframe>`)
+
+func stripIframes(html string) string {
+	return iframeTag.ReplaceAllStringFunc(html, func(tag string) string {
+		attrs := iframeTag.FindStringSubmatch(tag)[1]
+		if strings.Contains(attrs, ` src="`) && strings.Contains(attrs, ` sandbox="`) {
+			return tag
+		}
+		return ""
+	})
+}
+
+// Only iframes with the embed url of an allowed provider pass the sanitizer.
+func embedSources(names []string) *regexp.Regexp {
+	prefixes := []string{}
+	for _, name := range names {
+		if p, ok := providers[name]; ok {
+			prefixes = append(prefixes, regexp.QuoteMeta(p.prefix))
+		}
+	}
+	if len(prefixes) == 0 {
+		// Matches nothing, so every iframe is stripped
+		return regexp.MustCompile(`^\b$`)
+	}
+	return regexp.MustCompile(`^(?:` + strings.Join(prefixes, "|") + `)[A-Za-z0-9_/-]+$`)
+}
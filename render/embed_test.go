@@ -0,0 +1,76 @@
+package render
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestEmbedSources(t *testing.T) {
+
+	tests := []struct {
+		names []string
+		src   string
+		want  bool
+	}{
+		{names: []string{"youtube"}, src: "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", want: true},
+		{names: []string{"youtube", "vimeo"}, src: "https://player.vimeo.com/video/76979871", want: true},
+		{names: []string{"vimeo"}, src: "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{names: []string{"youtube"}, src: "https://www.youtube-nocookie.com/embed/x?autoplay=1"},
+		{names: []string{"youtube"}, src: "https://evil.com/https://www.youtube-nocookie.com/embed/x"},
+		{names: []string{"unknown"}, src: "https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"},
+		{names: nil, src: ""},
+	}
+
+	for _, tt := range tests {
+		if got := embedSources(tt.names).MatchString(tt.src); got != tt.want {
+			t.Errorf("%v matches %q: got %v, want %v", tt.names, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestStripIframes(t *testing.T) {
+
+	tests := []struct {
+		html string
+		want string
+	}{
+		{
+			html: `<p>a</p><iframe src="https://player.vimeo.com/video/1" sandbox="allow-scripts"></iframe>`,
+			want: `<p>a</p><iframe src="https://player.vimeo.com/video/1" sandbox="allow-scripts"></iframe>`,
+		},
+		// The sanitizer removed the src of a provider that is not allowed
+		{
+			html: `<p>a</p><iframe sandbox="allow-scripts"></iframe>`,
+			want: `<p>a</p>`,
+		},
+		// Written as html by the author
+		{
+			html: "<iframe src=\"https://player.vimeo.com/video/1\">\n</iframe><p>b</p>",
+			want: `<p>b</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := stripIframes(tt.html); got != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestRenderEmbeds(t *testing.T) {
+
+	text := "https://www.youtube.com/watch?v=dQw4w9WgXcQ\n\nhttps://vimeo.com/76979871\n\n" +
+		`<iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"></iframe>`
+
+	html := New(Options{EmbedProviders: []string{"youtube"}}).RenderText(context.Background(), &nostr.Event{}, text).HTML
+
+	if strings.Count(html, "<iframe") != 1 || !strings.Contains(html, `src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"`) {
+		t.Fatalf("want only the youtube embed, got %s", html)
+	}
+	if !strings.Contains(html, `href="https://vimeo.com/76979871"`) {
+		t.Fatalf("vimeo is not allowed and should stay a link, got %s", html)
+	}
+}
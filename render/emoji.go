@@ -0,0 +1,60 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var (
+	shortcodePattern = regexp.MustCompile(`:([a-zA-Z0-9_]+):`)
+	emojiShortcode   = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+)
+
+// A NIP-30 custom emoji, defined by an ["emoji", shortcode, url] tag.
+type Emoji struct {
+	ast.Leaf
+	Shortcode string
+	URL       string
+}
+
+func parseEmojiTags(tags nostr.Tags) map[string]string {
+	emoji := map[string]string{}
+	for _, t := range tags {
+		if len(t) < 3 || t[0] != "emoji" || !emojiShortcode.MatchString(t[1]) || t[2] == "" {
+			continue
+		}
+		emoji[t[1]] = t[2]
+	}
+	return emoji
+}
+
+// The literal is kept, so the outline and excerpts show the shortcode.
+//
+// Shortcodes without an emoji tag are left as text, so times like 10:30:00
+// are not affected.
+func parseEmoji(doc ast.Node, emoji map[string]string) {
+
+	if len(emoji) == 0 {
+		return
+	}
+
+	replaceText(doc, shortcodePattern, func(match [][]byte) ast.Node {
+		url, ok := emoji[string(match[1])]
+		if !ok {
+			return nil
+		}
+		e := &Emoji{Shortcode: string(match[1]), URL: url}
+		e.Literal = match[0]
+		return e
+	})
+}
+
+func renderEmoji(w io.Writer, e *Emoji) {
+	code := template.HTMLEscapeString(":" + e.Shortcode + ":")
+	fmt.Fprintf(w, `<img class="emoji" src="%s" alt="%s" title="%s">`, template.HTMLEscapeString(e.URL), code, code)
+}
@@ -0,0 +1,44 @@
+package render
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdCodeBlock = regexp.MustCompile("(?s)```.*?```")
+	mdCallout   = regexp.MustCompile(`(?m)^(\s*>\s*)\[![a-zA-Z]+\][+-]?`)
+	mdImage     = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	mdLink      = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdHTML      = regexp.MustCompile(`<[^>]+>`)
+	mdSyntax    = regexp.MustCompile("(?m)^\\s*(#+|>|[-*+]|\\d+\\.)\\s+|[*_`~]")
+)
+
+// The prose of markdown content, without code blocks, images and syntax.
+func PlainText(content string) string {
+	text := mdCodeBlock.ReplaceAllString(content, "")
+	text = mdCallout.ReplaceAllString(text, "$1")
+	text = mdImage.ReplaceAllString(text, "")
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdHTML.ReplaceAllString(text, "")
+	text = mdSyntax.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Plain text preview of markdown content, cut off at a word boundary.
+func Excerpt(content string, n int) string {
+
+	text := PlainText(content)
+
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+
+	return cut + "…"
+}
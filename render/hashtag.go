@@ -0,0 +1,45 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// A hashtag starts a word and contains at least one letter, so headings in
+// plain text, anchors in urls and issue numbers like #12 are left alone.
+var hashtagPattern = regexp.MustCompile(`(^|[\s(])#([\p{L}\p{N}_]*\p{L}[\p{L}\p{N}_-]*)`)
+
+type Hashtag struct {
+	ast.Leaf
+	// Whitespace matched in front of the #, written back as is.
+	Lead string
+	Tag  string
+}
+
+func parseHashtags(doc ast.Node) {
+	replaceText(doc, hashtagPattern, func(match [][]byte) ast.Node {
+		h := &Hashtag{Lead: string(match[1]), Tag: string(match[2])}
+		h.Literal = match[0]
+		return h
+	})
+}
+
+// Tags are lowercase, following the "t" tags of NIP-24.
+func TagURL(tag string) string {
+	return "/tags/" + url.PathEscape(strings.ToLower(tag))
+}
+
+func renderHashtag(w io.Writer, h *Hashtag, skipLinks bool) {
+	io.WriteString(w, template.HTMLEscapeString(h.Lead))
+	if skipLinks {
+		io.WriteString(w, template.HTMLEscapeString("#"+h.Tag))
+		return
+	}
+	fmt.Fprintf(w, `<a class="hashtag" href="%s">#%s</a>`, template.HTMLEscapeString(TagURL(h.Tag)), template.HTMLEscapeString(h.Tag))
+}
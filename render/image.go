@@ -0,0 +1,88 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var imageDimensions = regexp.MustCompile(`^([0-9]+)x([0-9]+)$`)
+
+// Metadata of a media file attached to the event with a NIP-92 imeta tag.
+type imeta struct {
+	Width  string
+	Height string
+	Alt    string
+}
+
+// Each imeta tag is a list of "key value" entries, one of which is the url.
+//
+//	["imeta", "url https://…/a.jpg", "dim 1920x1080", "alt A cat"]
+func parseImeta(tags nostr.Tags) map[string]imeta {
+
+	media := map[string]imeta{}
+
+	for _, t := range tags {
+		if len(t) < 2 || t[0] != "imeta" {
+			continue
+		}
+
+		var url string
+		var m imeta
+
+		for _, entry := range t[1:] {
+			key, value, _ := strings.Cut(entry, " ")
+			switch key {
+			case "url":
+				url = value
+			case "dim":
+				if d := imageDimensions.FindStringSubmatch(value); d != nil {
+					m.Width, m.Height = d[1], d[2]
+				}
+			case "alt":
+				m.Alt = value
+			}
+		}
+
+		if url != "" {
+			media[url] = m
+		}
+	}
+
+	return media
+}
+
+// Images are lazy loaded. The dimensions from imeta let the browser reserve
+// the space, so the page does not jump around while they load.
+func (d document) renderImage(w io.Writer, img *ast.Image, withTitle bool) {
+
+	src := string(img.Destination)
+	m := d.media[src]
+
+	alt := plainText(img)
+	if alt == "" {
+		alt = m.Alt
+	}
+
+	fmt.Fprintf(w, `<img src="%s" alt="%s" loading="lazy"`, template.HTMLEscapeString(src), template.HTMLEscapeString(alt))
+	if m.Width != "" {
+		fmt.Fprintf(w, ` width="%s" height="%s"`, m.Width, m.Height)
+	}
+	if withTitle && len(img.Title) > 0 {
+		fmt.Fprintf(w, ` title="%s"`, template.HTMLEscapeString(string(img.Title)))
+	}
+	io.WriteString(w, ">")
+}
+
+// An image with a title on its own line becomes a figure with the title as
+// caption, instead of a tooltip nobody sees.
+func (d document) renderFigure(w io.Writer, img *ast.Image) {
+	io.WriteString(w, "<figure>")
+	d.renderImage(w, img, false)
+	fmt.Fprintf(w, "<figcaption>%s</figcaption></figure>\n", template.HTMLEscapeString(string(img.Title)))
+}
@@ -0,0 +1,116 @@
+package render
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Replace every match of re in the text nodes of doc with the node returned by
+// fn. The match is kept as text when fn returns nil. Text that is already part
+// of a link is left alone.
+func replaceText(doc ast.Node, re *regexp.Regexp, fn func(match [][]byte) ast.Node) {
+
+	texts := []*ast.Text{}
+
+	// Collect first, since the tree cannot be modified while walking it
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if text, ok := node.(*ast.Text); ok && entering && !insideLink(text) {
+			texts = append(texts, text)
+		}
+		return ast.GoToNext
+	})
+
+	for _, text := range texts {
+
+		matches := re.FindAllSubmatchIndex(text.Literal, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		nodes := []ast.Node{}
+		last := 0
+
+		for _, m := range matches {
+
+			groups := [][]byte{}
+			for i := 0; i < len(m); i += 2 {
+				if m[i] < 0 {
+					groups = append(groups, nil)
+					continue
+				}
+				groups = append(groups, text.Literal[m[i]:m[i+1]])
+			}
+
+			node := fn(groups)
+			if node == nil {
+				continue
+			}
+
+			if m[0] > last {
+				nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: text.Literal[last:m[0]]}})
+			}
+			nodes = append(nodes, node)
+			last = m[1]
+		}
+
+		if len(nodes) == 0 {
+			continue
+		}
+
+		if last < len(text.Literal) {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: text.Literal[last:]}})
+		}
+
+		replaceNode(text, nodes)
+	}
+}
+
+// Swap a node for a list of siblings.
+func replaceNode(old ast.Node, nodes []ast.Node) {
+
+	parent := old.GetParent()
+	children := []ast.Node{}
+
+	for _, child := range parent.GetChildren() {
+		if child != old {
+			children = append(children, child)
+			continue
+		}
+		for _, n := range nodes {
+			n.SetParent(parent)
+			children = append(children, n)
+		}
+	}
+
+	parent.SetChildren(children)
+}
+
+func insideLink(node ast.Node) bool {
+	for p := node.GetParent(); p != nil; p = p.GetParent() {
+		if _, ok := p.(*ast.Link); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// A paragraph that contains nothing but the given node, ignoring whitespace.
+// Embeds like quote cards are rendered in place of such a paragraph.
+func onlyChild(p *ast.Paragraph) ast.Node {
+
+	var found ast.Node
+
+	for _, child := range p.Children {
+		if text, ok := child.(*ast.Text); ok && len(bytes.TrimSpace(text.Literal)) == 0 {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = child
+	}
+
+	return found
+}
@@ -0,0 +1,549 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Render $...$ and $$...$$ as MathML, so no javascript is needed to display
+// it. If the TeX uses something we do not support, the source is shown instead.
+func renderMath(w io.Writer, tex string, display bool) {
+
+	mathml, err := latexToMathML(tex, display)
+	if err == nil {
+		io.WriteString(w, mathml)
+		return
+	}
+
+	delimiter := "$"
+	if display {
+		delimiter = "$$"
+	}
+
+	fmt.Fprintf(w, `<code class="math-error" title="%s">%s</code>`,
+		template.HTMLEscapeString(err.Error()),
+		template.HTMLEscapeString(delimiter+tex+delimiter),
+	)
+}
+
+// $$...$$ within a paragraph. The parser only knows display math that starts
+// a block, and finds $...$ between the inner dollars otherwise.
+type DisplayMath struct {
+	ast.Leaf
+}
+
+// Fix up the $...$ the parser found, which pairs each $ with the next one.
+//
+//  1. Math between text ending and starting with a $ was $$...$$.
+//  2. Math that is not inline math, like "$5, and $" in "$5, and $x$", is
+//     text. Its closing $ could open math still, so the text after it is
+//     scanned again.
+func parseMath(doc ast.Node) {
+
+	maths := []*ast.Math{}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if m, ok := node.(*ast.Math); ok && entering {
+			maths = append(maths, m)
+		}
+		return ast.GoToNext
+	})
+
+	for len(maths) != 0 {
+
+		m := maths[0]
+		maths = maths[1:]
+
+		prev, _ := ast.GetPrevNode(m).(*ast.Text)
+		next, _ := ast.GetNextNode(m).(*ast.Text)
+
+		if prev != nil && next != nil && bytes.HasSuffix(prev.Literal, []byte("$")) && bytes.HasPrefix(next.Literal, []byte("$")) {
+			prev.Literal = prev.Literal[:len(prev.Literal)-1]
+			next.Literal = next.Literal[1:]
+			replaceNode(m, []ast.Node{&DisplayMath{Leaf: ast.Leaf{Literal: m.Literal}}})
+			continue
+		}
+
+		if isInlineMath(m) {
+			continue
+		}
+
+		rest := []byte("$")
+		if next != nil {
+			rest = append(rest, next.Literal...)
+			replaceNode(next, nil)
+		}
+
+		nodes := []ast.Node{&ast.Text{Leaf: ast.Leaf{Literal: append([]byte("$"), m.Literal...)}}}
+
+		// The closing $ opens math up to the next one, like the parser does
+		if end := bytes.IndexByte(rest[1:], '$') + 1; end > 1 {
+			next := &ast.Math{Leaf: ast.Leaf{Literal: rest[1:end]}}
+			maths = append([]*ast.Math{next}, maths...)
+			nodes = append(nodes, next)
+			rest = rest[end+1:]
+		}
+		if len(rest) > 0 {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: rest}})
+		}
+
+		replaceNode(m, nodes)
+	}
+}
+
+// Prices like "$5 and $10" are not math. Following pandoc, the opening $ must
+// be followed by a non-space, the closing $ preceded by a non-space and not
+// followed by a digit.
+func isInlineMath(node *ast.Math) bool {
+
+	tex := string(node.Literal)
+	if tex == "" || unicode.IsSpace(rune(tex[0])) || unicode.IsSpace(rune(tex[len(tex)-1])) {
+		return false
+	}
+
+	if next := ast.GetNextNode(node); next != nil {
+		if text, ok := next.(*ast.Text); ok && len(text.Literal) > 0 && unicode.IsDigit(rune(text.Literal[0])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func latexToMathML(tex string, display bool) (string, error) {
+
+	p := &mathParser{tokens: tokenize(tex), display: display}
+
+	body, err := p.parseExpr("")
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.tokens) {
+		return "", fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+
+	mode := "inline"
+	if display {
+		mode = "block"
+	}
+
+	return fmt.Sprintf(`<math display="%s"><semantics><mrow>%s</mrow><annotation encoding="application/x-tex">%s</annotation></semantics></math>`,
+		mode, body, template.HTMLEscapeString(tex)), nil
+}
+
+// Split TeX into commands (\frac), single letters, numbers, symbols and spaces.
+func tokenize(tex string) []string {
+
+	tokens := []string{}
+	runes := []rune(tex)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			// Only kept for \text, everything else skips it
+			for i < len(runes) && unicode.IsSpace(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, " ")
+		case r == '\\':
+			j := i + 1
+			for j < len(runes) && unicode.IsLetter(runes[j]) {
+				j++
+			}
+			if j == i+1 && j < len(runes) {
+				j++ // single symbol commands like \{ or \,
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case unicode.IsDigit(r) || r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+
+	return tokens
+}
+
+type mathParser struct {
+	tokens  []string
+	pos     int
+	display bool
+}
+
+func (p *mathParser) peek() string {
+	for p.pos < len(p.tokens) && p.tokens[p.pos] == " " {
+		p.pos++
+	}
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *mathParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *mathParser) expect(t string) error {
+	if got := p.next(); got != t {
+		return fmt.Errorf("expected %q, got %q", t, got)
+	}
+	return nil
+}
+
+// Parse until the closing token, which is left for the caller to consume.
+// Rows of an environment also end at & and \\.
+func (p *mathParser) parseExpr(until string) (string, error) {
+
+	var b strings.Builder
+
+	for {
+		t := p.peek()
+		if t == "" || t == until || t == "}" || t == `\right` || t == `\end` || t == "&" || t == `\\` {
+			break
+		}
+		atom, err := p.parseScripts()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(atom)
+	}
+
+	return b.String(), nil
+}
+
+// An atom followed by optional sub and superscripts.
+func (p *mathParser) parseScripts() (string, error) {
+
+	t := p.peek()
+
+	base, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+
+	var sub, sup string
+
+	for {
+		switch p.peek() {
+		case "_":
+			p.next()
+			if sub, err = p.parseArg(); err != nil {
+				return "", err
+			}
+			continue
+		case "^":
+			p.next()
+			if sup, err = p.parseArg(); err != nil {
+				return "", err
+			}
+			continue
+		case "'":
+			p.next()
+			sup += "<mo>′</mo>"
+			continue
+		}
+		break
+	}
+
+	// Limits go above and below large operators in display math
+	under, over, both := "msub", "msup", "msubsup"
+	if _, ok := largeOperators[t]; ok && p.display || t == `\lim` {
+		under, over, both = "munder", "mover", "munderover"
+	}
+
+	switch {
+	case sub != "" && sup != "":
+		return fmt.Sprintf("<%s>%s<mrow>%s</mrow><mrow>%s</mrow></%s>", both, base, sub, sup, both), nil
+	case sub != "":
+		return fmt.Sprintf("<%s>%s<mrow>%s</mrow></%s>", under, base, sub, under), nil
+	case sup != "":
+		return fmt.Sprintf("<%s>%s<mrow>%s</mrow></%s>", over, base, sup, over), nil
+	}
+
+	return base, nil
+}
+
+// A group in braces or a single atom, like the arguments of \frac.
+func (p *mathParser) parseArg() (string, error) {
+	if p.peek() == "" {
+		return "", fmt.Errorf("missing argument")
+	}
+	return p.parseAtom()
+}
+
+// Raw text of a group in braces, used by \text and \begin.
+func (p *mathParser) parseText() (string, error) {
+
+	if err := p.expect("{"); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for depth := 0; ; p.pos++ {
+		t := ""
+		if p.pos < len(p.tokens) {
+			t = p.tokens[p.pos]
+		}
+		switch t {
+		case "":
+			return "", fmt.Errorf("missing }")
+		case "{":
+			depth++
+		case "}":
+			if depth == 0 {
+				p.pos++
+				return b.String(), nil
+			}
+			depth--
+		}
+		b.WriteString(strings.TrimPrefix(t, `\`))
+	}
+}
+
+func (p *mathParser) parseAtom() (string, error) {
+
+	t := p.next()
+
+	switch {
+	case t == "{":
+		inner, err := p.parseExpr("}")
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("}"); err != nil {
+			return "", err
+		}
+		return "<mrow>" + inner + "</mrow>", nil
+	case t == "":
+		return "", fmt.Errorf("unexpected end of math")
+	case t == "}" || t == "^" || t == "_" || t == "&":
+		return "", fmt.Errorf("unexpected %q", t)
+	case unicode.IsDigit([]rune(t)[0]) || t[0] == '.' && len(t) > 1:
+		return "<mn>" + t + "</mn>", nil
+	case unicode.IsLetter([]rune(t)[0]):
+		return "<mi>" + template.HTMLEscapeString(t) + "</mi>", nil
+	case t[0] != '\\':
+		return "<mo>" + template.HTMLEscapeString(t) + "</mo>", nil
+	}
+
+	if v, ok := greekLetters[t]; ok {
+		if unicode.IsUpper([]rune(v)[0]) {
+			return `<mi mathvariant="normal">` + v + "</mi>", nil
+		}
+		return "<mi>" + v + "</mi>", nil
+	}
+	if v, ok := mathSymbols[t]; ok {
+		return "<mi>" + v + "</mi>", nil
+	}
+	if v, ok := mathOperators[t]; ok {
+		return "<mo>" + template.HTMLEscapeString(v) + "</mo>", nil
+	}
+	if v, ok := largeOperators[t]; ok {
+		return `<mo largeop="true">` + v + "</mo>", nil
+	}
+	if v, ok := mathSpaces[t]; ok {
+		return `<mspace width="` + v + `"></mspace>`, nil
+	}
+	if v, ok := accents[t]; ok {
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", err
+		}
+		if t == `\underline` {
+			return fmt.Sprintf(`<munder>%s<mo>%s</mo></munder>`, arg, v), nil
+		}
+		return fmt.Sprintf(`<mover accent="true">%s<mo>%s</mo></mover>`, arg, v), nil
+	}
+	if functions[t] {
+		return "<mi>" + t[1:] + "</mi>", nil
+	}
+
+	switch t {
+	case `\frac`, `\dfrac`, `\tfrac`:
+		num, err := p.parseArg()
+		if err != nil {
+			return "", err
+		}
+		den, err := p.parseArg()
+		if err != nil {
+			return "", err
+		}
+		return "<mfrac>" + num + den + "</mfrac>", nil
+
+	case `\sqrt`:
+		if p.peek() == "[" {
+			p.next()
+			index, err := p.parseExpr("]")
+			if err != nil {
+				return "", err
+			}
+			if err := p.expect("]"); err != nil {
+				return "", err
+			}
+			arg, err := p.parseArg()
+			if err != nil {
+				return "", err
+			}
+			return "<mroot>" + arg + "<mrow>" + index + "</mrow></mroot>", nil
+		}
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", err
+		}
+		return "<msqrt>" + arg + "</msqrt>", nil
+
+	case `\text`, `\textrm`, `\mbox`:
+		text, err := p.parseText()
+		if err != nil {
+			return "", err
+		}
+		return "<mtext>" + template.HTMLEscapeString(text) + "</mtext>", nil
+
+	case `\mathrm`, `\operatorname`:
+		text, err := p.parseText()
+		if err != nil {
+			return "", err
+		}
+		return `<mi mathvariant="normal">` + template.HTMLEscapeString(text) + "</mi>", nil
+
+	case `\mathbf`, `\mathit`, `\mathcal`, `\mathbb`:
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", err
+		}
+		return mathVariant(arg, t), nil
+
+	case `\left`:
+		open, err := p.delimiter()
+		if err != nil {
+			return "", err
+		}
+		inner, err := p.parseExpr(`\right`)
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect(`\right`); err != nil {
+			return "", err
+		}
+		closing, err := p.delimiter()
+		if err != nil {
+			return "", err
+		}
+		return "<mrow>" + open + inner + closing + "</mrow>", nil
+
+	case `\begin`:
+		return p.parseEnvironment()
+	}
+
+	return "", fmt.Errorf("unsupported command %s", t)
+}
+
+// The delimiter after \left or \right, where "." means no delimiter.
+func (p *mathParser) delimiter() (string, error) {
+	t := p.next()
+	if t == "." {
+		return "", nil
+	}
+	if v, ok := mathOperators[t]; ok {
+		t = v
+	} else if len([]rune(t)) != 1 {
+		return "", fmt.Errorf("unsupported delimiter %q", t)
+	}
+	return `<mo stretchy="true">` + template.HTMLEscapeString(t) + "</mo>", nil
+}
+
+// Matrices and cases, with cells split by & and rows by \\.
+func (p *mathParser) parseEnvironment() (string, error) {
+
+	name, err := p.parseText()
+	if err != nil {
+		return "", err
+	}
+
+	env, ok := environments[name]
+	if !ok {
+		return "", fmt.Errorf("unsupported environment %s", name)
+	}
+
+	var table strings.Builder
+	table.WriteString("<mtable><mtr>")
+
+	for {
+		cell, err := p.parseExpr("")
+		if err != nil {
+			return "", err
+		}
+		table.WriteString("<mtd>" + cell + "</mtd>")
+
+		switch p.next() {
+		case "&":
+			continue
+		case `\\`:
+			table.WriteString("</mtr><mtr>")
+			continue
+		case `\end`:
+			end, err := p.parseText()
+			if err != nil {
+				return "", err
+			}
+			if end != name {
+				return "", fmt.Errorf("\\begin{%s} ended by \\end{%s}", name, end)
+			}
+		default:
+			return "", fmt.Errorf("\\begin{%s} is never ended", name)
+		}
+		break
+	}
+
+	table.WriteString("</mtr></mtable>")
+
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if env.open != "" {
+		b.WriteString(`<mo stretchy="true">` + env.open + "</mo>")
+	}
+	b.WriteString(table.String())
+	if env.close != "" {
+		b.WriteString(`<mo stretchy="true">` + env.close + "</mo>")
+	}
+	b.WriteString("</mrow>")
+
+	return b.String(), nil
+}
+
+// Apply a font to the identifiers of the argument. Only double-struck capitals
+// have to be mapped to unicode, since MathML Core does not support mathvariant.
+func mathVariant(arg, command string) string {
+
+	if command == `\mathbb` {
+		for letter, v := range doubleStruck {
+			arg = strings.ReplaceAll(arg, "<mi>"+letter+"</mi>", `<mi mathvariant="normal">`+v+"</mi>")
+		}
+		return arg
+	}
+
+	variant := map[string]string{
+		`\mathbf`:  "bold",
+		`\mathit`:  "italic",
+		`\mathcal`: "script",
+	}[command]
+
+	return strings.ReplaceAll(arg, "<mi>", `<mi mathvariant="`+variant+`">`)
+}
@@ -0,0 +1,88 @@
+package render
+
+var greekLetters = map[string]string{
+	`\alpha`: "α", `\beta`: "β", `\gamma`: "γ", `\delta`: "δ", `\epsilon`: "ϵ",
+	`\varepsilon`: "ε", `\zeta`: "ζ", `\eta`: "η", `\theta`: "θ", `\vartheta`: "ϑ",
+	`\iota`: "ι", `\kappa`: "κ", `\lambda`: "λ", `\mu`: "μ", `\nu`: "ν", `\xi`: "ξ",
+	`\pi`: "π", `\varpi`: "ϖ", `\rho`: "ρ", `\varrho`: "ϱ", `\sigma`: "σ",
+	`\varsigma`: "ς", `\tau`: "τ", `\upsilon`: "υ", `\phi`: "ϕ", `\varphi`: "φ",
+	`\chi`: "χ", `\psi`: "ψ", `\omega`: "ω",
+	`\Gamma`: "Γ", `\Delta`: "Δ", `\Theta`: "Θ", `\Lambda`: "Λ", `\Xi`: "Ξ",
+	`\Pi`: "Π", `\Sigma`: "Σ", `\Upsilon`: "Υ", `\Phi`: "Φ", `\Psi`: "Ψ", `\Omega`: "Ω",
+}
+
+// Symbols that behave like identifiers.
+var mathSymbols = map[string]string{
+	`\infty`: "∞", `\partial`: "∂", `\nabla`: "∇", `\emptyset`: "∅", `\varnothing`: "∅",
+	`\ell`: "ℓ", `\hbar`: "ℏ", `\aleph`: "ℵ", `\Re`: "ℜ", `\Im`: "ℑ",
+}
+
+var mathOperators = map[string]string{
+	`\times`: "×", `\cdot`: "⋅", `\pm`: "±", `\mp`: "∓", `\div`: "÷", `\ast`: "∗",
+	`\star`: "⋆", `\circ`: "∘", `\bullet`: "∙", `\oplus`: "⊕", `\otimes`: "⊗",
+	`\leq`: "≤", `\le`: "≤", `\geq`: "≥", `\ge`: "≥", `\neq`: "≠", `\ne`: "≠",
+	`\ll`: "≪", `\gg`: "≫", `\approx`: "≈", `\equiv`: "≡", `\sim`: "∼",
+	`\simeq`: "≃", `\cong`: "≅", `\propto`: "∝", `\perp`: "⊥", `\parallel`: "∥",
+	`\mid`: "∣", `\to`: "→", `\rightarrow`: "→", `\leftarrow`: "←", `\gets`: "←",
+	`\leftrightarrow`: "↔", `\Rightarrow`: "⇒", `\Leftarrow`: "⇐",
+	`\Leftrightarrow`: "⇔", `\implies`: "⟹", `\iff`: "⟺", `\mapsto`: "↦",
+	`\in`: "∈", `\notin`: "∉", `\ni`: "∋", `\subset`: "⊂", `\supset`: "⊃",
+	`\subseteq`: "⊆", `\supseteq`: "⊇", `\cup`: "∪", `\cap`: "∩", `\setminus`: "∖",
+	`\forall`: "∀", `\exists`: "∃", `\neg`: "¬", `\lnot`: "¬", `\land`: "∧",
+	`\wedge`: "∧", `\lor`: "∨", `\vee`: "∨", `\ldots`: "…", `\dots`: "…",
+	`\cdots`: "⋯", `\vdots`: "⋮", `\ddots`: "⋱", `\langle`: "⟨", `\rangle`: "⟩",
+	`\lfloor`: "⌊", `\rfloor`: "⌋", `\lceil`: "⌈", `\rceil`: "⌉",
+	`\{`: "{", `\}`: "}", `\|`: "‖", `\vert`: "|", `\Vert`: "‖",
+	`\%`: "%", `\$`: "$", `\#`: "#", `\&`: "&", `\_`: "_",
+}
+
+// Operators that take limits, like sums and integrals.
+var largeOperators = map[string]string{
+	`\sum`: "∑", `\prod`: "∏", `\coprod`: "∐", `\int`: "∫", `\iint`: "∬",
+	`\iiint`: "∭", `\oint`: "∮", `\bigcup`: "⋃", `\bigcap`: "⋂",
+	`\bigoplus`: "⨁", `\bigotimes`: "⨂",
+}
+
+var mathSpaces = map[string]string{
+	`\,`: "0.167em", `\:`: "0.222em", `\;`: "0.278em", `\ `: "0.25em",
+	`\quad`: "1em", `\qquad`: "2em", `\!`: "-0.167em",
+}
+
+var accents = map[string]string{
+	`\hat`: "^", `\widehat`: "^", `\bar`: "¯", `\overline`: "¯", `\vec`: "→",
+	`\dot`: "˙", `\ddot`: "¨", `\tilde`: "~", `\widetilde`: "~", `\underline`: "_",
+}
+
+// Function names are upright, which is the default for multi letter identifiers.
+var functions = map[string]bool{
+	`\sin`: true, `\cos`: true, `\tan`: true, `\sec`: true, `\csc`: true, `\cot`: true,
+	`\arcsin`: true, `\arccos`: true, `\arctan`: true, `\sinh`: true, `\cosh`: true,
+	`\tanh`: true, `\log`: true, `\ln`: true, `\lg`: true, `\exp`: true, `\lim`: true,
+	`\max`: true, `\min`: true, `\sup`: true, `\inf`: true, `\det`: true, `\dim`: true,
+	`\ker`: true, `\deg`: true, `\gcd`: true, `\arg`: true, `\Pr`: true,
+}
+
+var doubleStruck = map[string]string{
+	"A": "𝔸", "B": "𝔹", "C": "ℂ", "D": "𝔻", "E": "𝔼", "F": "𝔽", "G": "𝔾", "H": "ℍ",
+	"I": "𝕀", "J": "𝕁", "K": "𝕂", "L": "𝕃", "M": "𝕄", "N": "ℕ", "O": "𝕆", "P": "ℙ",
+	"Q": "ℚ", "R": "ℝ", "S": "𝕊", "T": "𝕋", "U": "𝕌", "V": "𝕍", "W": "𝕎", "X": "𝕏",
+	"Y": "𝕐", "Z": "ℤ",
+}
+
+type environment struct {
+	open, close string
+}
+
+var environments = map[string]environment{
+	"matrix":   {},
+	"pmatrix":  {"(", ")"},
+	"bmatrix":  {"[", "]"},
+	"Bmatrix":  {"{", "}"},
+	"vmatrix":  {"|", "|"},
+	"Vmatrix":  {"‖", "‖"},
+	"cases":    {"{", ""},
+	"aligned":  {},
+	"align":    {},
+	"align*":   {},
+	"gathered": {},
+}
@@ -0,0 +1,148 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestTokenize(t *testing.T) {
+
+	got := tokenize(`\frac{12.5}{x_i} + \alpha`)
+	want := []string{`\frac`, "{", "12.5", "}", "{", "x", "_", "i", "}", " ", "+", " ", `\alpha`}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestLatexToMathML(t *testing.T) {
+
+	tests := []struct {
+		tex  string
+		want string
+		err  string
+	}{
+		{tex: `x^2`, want: `<msup><mi>x</mi><mrow><mn>2</mn></mrow></msup>`},
+		{tex: `x_i^2`, want: `<msubsup><mi>x</mi><mrow><mi>i</mi></mrow><mrow><mn>2</mn></mrow></msubsup>`},
+		{tex: `\frac{a}{b}`, want: `<mfrac><mrow><mi>a</mi></mrow><mrow><mi>b</mi></mrow></mfrac>`},
+		{tex: `\sqrt{x}`, want: `<msqrt><mrow><mi>x</mi></mrow></msqrt>`},
+		{tex: `\sqrt[3]{x}`, want: `<mroot><mrow><mi>x</mi></mrow><mrow><mn>3</mn></mrow></mroot>`},
+		{tex: `\alpha + \beta`, want: `<mi>α</mi><mo>+</mo><mi>β</mi>`},
+		{tex: `12.5`, want: `<mn>12.5</mn>`},
+		{tex: `a < b`, want: `<mi>a</mi><mo>&lt;</mo><mi>b</mi>`},
+		{tex: `\mathbb{R}`, want: `<mrow><mi mathvariant="normal">ℝ</mi></mrow>`},
+		{tex: `\text{if } x`, want: `<mtext>if </mtext><mi>x</mi>`},
+		{tex: `\left( x \right)`, want: `<mrow><mo stretchy="true">(</mo><mi>x</mi><mo stretchy="true">)</mo></mrow>`},
+		{tex: `\sum_{i=0}^n i`, want: `<msubsup><mo largeop="true">∑</mo><mrow><mrow><mi>i</mi><mo>=</mo><mn>0</mn></mrow></mrow><mrow><mi>n</mi></mrow></msubsup><mi>i</mi>`},
+		{
+			tex:  `\begin{matrix} a & b \\ c & d \end{matrix}`,
+			want: `<mrow><mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable></mrow>`,
+		},
+		{tex: `\frac{a}{`, err: `expected "}", got ""`},
+		{tex: `x^`, err: `missing argument`},
+		{tex: `}`, err: `unexpected "}"`},
+		{tex: `\unknown`, err: `unsupported command \unknown`},
+		{tex: `\begin{matrix} a \end{pmatrix}`, err: `\begin{matrix} ended by \end{pmatrix}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tex, func(t *testing.T) {
+
+			mathml, err := latexToMathML(tt.tex, false)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, body, _ := strings.Cut(mathml, "<semantics><mrow>")
+			body, _, _ = strings.Cut(body, "</mrow><annotation")
+			if body != tt.want {
+				t.Fatalf("got %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMath(t *testing.T) {
+
+	var b bytes.Buffer
+	renderMath(&b, `a<b`, true)
+
+	want := `<math display="block"><semantics><mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow><annotation encoding="application/x-tex">a&lt;b</annotation></semantics></math>`
+	if b.String() != want {
+		t.Fatalf("got %s, want %s", b.String(), want)
+	}
+
+	// The source is shown when the TeX is not supported
+	b.Reset()
+	renderMath(&b, `\unknown<`, false)
+
+	want = `<code class="math-error" title="unsupported command \unknown">$\unknown&lt;$</code>`
+	if b.String() != want {
+		t.Fatalf("got %s, want %s", b.String(), want)
+	}
+}
+
+func TestRenderInlineMath(t *testing.T) {
+
+	tests := []struct {
+		text    string
+		display []string
+		inline  []string
+		want    string
+	}{
+		{
+			text:    `Euler: $$e^{i\pi}+1=0$$ is nice`,
+			display: []string{`e^{i\pi}+1=0`},
+			want:    `Euler:`,
+		},
+		{
+			text:   `cost is $5, and $x$ is a var`,
+			inline: []string{`x`},
+			want:   `cost is $5, and `,
+		},
+		{
+			text: `from $5 to $10`,
+			want: `from $5 to $10`,
+		},
+		{
+			text:   `$a$ and $b$`,
+			inline: []string{`a`, `b`},
+		},
+	}
+
+	r := New(Options{})
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+
+			html := string(r.RenderText(context.Background(), &nostr.Event{}, tt.text).HTML)
+
+			if !strings.Contains(html, tt.want) || strings.Count(html, "<math") != len(tt.display)+len(tt.inline) {
+				t.Fatalf("got %s", html)
+			}
+			for _, tex := range append(tt.display, tt.inline...) {
+				if !strings.Contains(html, template.HTMLEscapeString(tex)) {
+					t.Errorf("%s is not rendered in %s", tex, html)
+				}
+			}
+			if len(tt.display) != 0 && !strings.Contains(html, `display="block"`) {
+				t.Errorf("not display math: %s", html)
+			}
+			if strings.Contains(html, "$<math") || strings.Contains(html, "</math>$") {
+				t.Errorf("stray $ around math: %s", html)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Fetch the events referenced by an article. All references are requested
+// with a single call, so implementations can send them as one relay query.
+type Fetcher interface {
+	FetchEvents(ctx context.Context, filters nostr.Filters) ([]*nostr.Event, error)
+}
+
+var bareReference = regexp.MustCompile(`nostr:((?:npub|nprofile|note|nevent|naddr)1[02-9ac-hj-np-z]+)`)
+
+// A bare NIP-27 reference in the text, like nostr:npub1...
+type Mention struct {
+	ast.Leaf
+	Code    string
+	Prefix  string
+	Pointer any
+}
+
+// Events needed to display the mentions of a single article.
+type references struct {
+	profiles  map[string]*nostr.Event
+	events    map[string]*nostr.Event
+	addresses map[string]*nostr.Event
+}
+
+func parseMentions(doc ast.Node) []*Mention {
+
+	mentions := []*Mention{}
+
+	replaceText(doc, bareReference, func(match [][]byte) ast.Node {
+		code := string(match[1])
+		prefix, data, err := nip19.Decode(code)
+		if err != nil {
+			return nil
+		}
+		m := &Mention{Code: code, Prefix: prefix, Pointer: data}
+		mentions = append(mentions, m)
+		return m
+	})
+
+	return mentions
+}
+
+// Request the profiles, notes and articles of all mentions at once. The
+// articles of the author are included when there are wikilinks to resolve.
+func (r *Renderer) fetchReferences(ctx context.Context, author string, mentions []*Mention, wikilinks []*WikiLink) references {
+
+	refs := references{
+		profiles:  map[string]*nostr.Event{},
+		events:    map[string]*nostr.Event{},
+		addresses: map[string]*nostr.Event{},
+	}
+
+	if author == "" {
+		wikilinks = nil
+	}
+
+	if r.opts.Fetcher == nil || len(mentions)+len(wikilinks) == 0 {
+		return refs
+	}
+
+	pubkeys := []string{}
+	ids := []string{}
+	filters := nostr.Filters{}
+
+	seen := map[string]bool{}
+	add := func(list *[]string, v string) {
+		if !seen[v] {
+			seen[v] = true
+			*list = append(*list, v)
+		}
+	}
+
+	for _, m := range mentions {
+		switch v := m.Pointer.(type) {
+		case nostr.ProfilePointer:
+			add(&pubkeys, v.PublicKey)
+		case nostr.EventPointer:
+			add(&ids, v.ID)
+			if v.Author != "" {
+				add(&pubkeys, v.Author)
+			}
+		case nostr.EntityPointer:
+			add(&pubkeys, v.PublicKey)
+			key := address(v.Kind, v.PublicKey, v.Identifier)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			filters = append(filters, nostr.Filter{
+				Kinds:   []int{v.Kind},
+				Authors: []string{v.PublicKey},
+				Tags:    nostr.TagMap{"d": []string{v.Identifier}},
+			})
+		case string:
+			if m.Prefix == "npub" {
+				add(&pubkeys, v)
+			} else {
+				add(&ids, v)
+			}
+		}
+	}
+
+	if len(wikilinks) != 0 {
+		filters = append(filters, nostr.Filter{Kinds: []int{nostr.KindArticle}, Authors: []string{author}})
+	}
+	if len(pubkeys) != 0 {
+		filters = append(filters, nostr.Filter{Kinds: []int{nostr.KindProfileMetadata}, Authors: pubkeys})
+	}
+	if len(ids) != 0 {
+		filters = append(filters, nostr.Filter{IDs: ids})
+	}
+
+	events, err := r.opts.Fetcher.FetchEvents(ctx, filters)
+	if err != nil {
+		// Mentions are still rendered as plain links
+		return refs
+	}
+
+	for _, e := range events {
+		switch {
+		case e.Kind == nostr.KindProfileMetadata:
+			refs.profiles[e.PubKey] = newest(refs.profiles[e.PubKey], e)
+		case e.Kind >= 30000 && e.Kind < 40000:
+			key := address(e.Kind, e.PubKey, e.Tags.GetD())
+			refs.addresses[key] = newest(refs.addresses[key], e)
+		}
+		refs.events[e.ID] = e
+	}
+
+	return refs
+}
+
+func newest(a, b *nostr.Event) *nostr.Event {
+	if a == nil || b.CreatedAt > a.CreatedAt {
+		return b
+	}
+	return a
+}
+
+func address(kind int, pubkey, identifier string) string {
+	return fmt.Sprintf("%d:%s:%s", kind, pubkey, identifier)
+}
+
+// The event a note, nevent or naddr mention points to, if it was found.
+func (s references) event(m *Mention) *nostr.Event {
+	switch v := m.Pointer.(type) {
+	case nostr.EventPointer:
+		return s.events[v.ID]
+	case nostr.EntityPointer:
+		return s.addresses[address(v.Kind, v.PublicKey, v.Identifier)]
+	case string:
+		if m.Prefix == "note" {
+			return s.events[v]
+		}
+	}
+	return nil
+}
+
+// Profile display name, falling back to a shortened npub.
+func (s references) name(pubkey string) string {
+
+	if e, ok := s.profiles[pubkey]; ok {
+		var profile struct {
+			Name        string `json:"name"`
+			DisplayName string `json:"display_name"`
+		}
+		if json.Unmarshal([]byte(e.Content), &profile) == nil {
+			if profile.DisplayName != "" {
+				return profile.DisplayName
+			}
+			if profile.Name != "" {
+				return profile.Name
+			}
+		}
+	}
+
+	npub, _ := nip19.EncodePublicKey(pubkey)
+	return shorten(npub)
+}
+
+func shorten(code string) string {
+	if len(code) < 16 {
+		return code
+	}
+	return code[:8] + "…" + code[len(code)-4:]
+}
+
+func mentionPubkey(m *Mention) string {
+	switch v := m.Pointer.(type) {
+	case nostr.ProfilePointer:
+		return v.PublicKey
+	case string:
+		if m.Prefix == "npub" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Inline mentions render as links. Profiles show the display name.
+func (s references) renderMention(w io.Writer, m *Mention) {
+
+	href := template.HTMLEscapeString(ReferenceURL(m.Code))
+
+	if pubkey := mentionPubkey(m); pubkey != "" {
+		fmt.Fprintf(w, `<a class="mention" href="%s">@%s</a>`, href, template.HTMLEscapeString(s.name(pubkey)))
+		return
+	}
+
+	text := shorten(m.Code)
+	if e := s.event(m); e != nil {
+		if title := e.Tags.GetFirst([]string{"title", ""}); title != nil && title.Value() != "" {
+			text = title.Value()
+		}
+	}
+
+	fmt.Fprintf(w, `<a class="inline" href="%s">%s</a>`, href, template.HTMLEscapeString(text))
+}
+
+// Mentions on their own line are embedded as cards, if the event was found.
+// 1. note and nevent become a quote of the note
+// 2. naddr becomes a preview of the article
+func (s references) renderCard(w io.Writer, m *Mention) {
+
+	e := s.event(m)
+
+	href := template.HTMLEscapeString(ReferenceURL(m.Code))
+
+	if m.Prefix == "naddr" {
+
+		title := e.Tags.GetFirst([]string{"title", ""})
+		summary := e.Tags.GetFirst([]string{"summary", ""})
+		image := e.Tags.GetFirst([]string{"image", ""})
+
+		fmt.Fprintf(w, `<aside class="article-preview"><a href="%s">`, href)
+		if image != nil && image.Value() != "" {
+			fmt.Fprintf(w, `<img src="%s" alt="">`, template.HTMLEscapeString(image.Value()))
+		}
+		if title != nil {
+			fmt.Fprintf(w, `<strong>%s</strong>`, template.HTMLEscapeString(title.Value()))
+		}
+		text := Excerpt(e.Content, 200)
+		if summary != nil && summary.Value() != "" {
+			text = summary.Value()
+		}
+		fmt.Fprintf(w, `<span>%s</span>`, template.HTMLEscapeString(text))
+		fmt.Fprintf(w, `<small>%s</small></a></aside>`, template.HTMLEscapeString(s.name(e.PubKey)))
+
+		return
+	}
+
+	fmt.Fprintf(w, `<blockquote class="quote-card"><a href="%s">@%s</a><p>%s</p></blockquote>`,
+		href,
+		template.HTMLEscapeString(s.name(e.PubKey)),
+		template.HTMLEscapeString(Excerpt(e.Content, 280)),
+	)
+}
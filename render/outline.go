@@ -0,0 +1,59 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+type Heading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// The rendered HTML along with the structure of the article.
+type Document struct {
+	HTML    string
+	Outline []Heading
+}
+
+// Collect the headings in order, used to build a table of contents.
+func outline(doc ast.Node) []Heading {
+
+	headings := []Heading{}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		h, ok := node.(*ast.Heading)
+		if !ok || !entering || h.HeadingID == "" || h.IsTitleblock {
+			return ast.GoToNext
+		}
+		headings = append(headings, Heading{
+			Level: h.Level,
+			ID:    h.HeadingID,
+			Text:  plainText(h),
+		})
+		return ast.SkipChildren
+	})
+
+	return headings
+}
+
+func plainText(node ast.Node) string {
+	var b strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if leaf := n.AsLeaf(); leaf != nil && entering {
+			b.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// Close the heading with a permalink to itself.
+func renderHeadingAnchor(w io.Writer, h *ast.Heading) {
+	fmt.Fprintf(w, `<a class="heading-anchor" href="#%s">#</a></h%d>`+"\n", template.HTMLEscapeString(h.HeadingID), h.Level)
+}
@@ -0,0 +1,96 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Point [text](nostr:...) links to our own routes.
+//
+// The destination is rebuilt from the decoded NIP-19 entity instead of the
+// captured text, so nothing the author wrote ends up in the attribute.
+// Links with an invalid code lose their href and are unwrapped by the sanitizer.
+func rewriteReferences(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		link, ok := node.(*ast.Link)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+
+		code, found := strings.CutPrefix(string(link.Destination), "nostr:")
+		if !found {
+			return ast.GoToNext
+		}
+
+		link.Destination = []byte(ReferenceURL(code))
+		link.AdditionalAttributes = append(link.AdditionalAttributes, `class="inline"`)
+
+		return ast.GoToNext
+	})
+}
+
+// The page on this site that displays the entity of a NIP-19 code, or an empty
+// string if the code is invalid.
+func ReferenceURL(code string) string {
+
+	prefix, data, err := nip19.Decode(code)
+	if err != nil {
+		return ""
+	}
+
+	switch v := data.(type) {
+	case nostr.ProfilePointer:
+		npub, _ := nip19.EncodePublicKey(v.PublicKey)
+		return "/nz/" + npub
+	case nostr.EntityPointer:
+		npub, _ := nip19.EncodePublicKey(v.PublicKey)
+		naddr, _ := nip19.EncodeEntity(v.PublicKey, v.Kind, v.Identifier, nil)
+		return "/nz/" + npub + "/" + naddr
+	case nostr.EventPointer:
+		nevent, _ := nip19.EncodeEvent(v.ID, nil, v.Author)
+		return "/nz/" + nevent
+	case string:
+		if prefix == "npub" {
+			npub, _ := nip19.EncodePublicKey(v)
+			return "/nz/" + npub
+		}
+		if prefix == "note" {
+			note, _ := nip19.EncodeNote(v)
+			return "/nz/" + note
+		}
+	}
+
+	return ""
+}
+
+// NIP-19 codes of all nostr: links and mentions in markdown content. Code
+// blocks are skipped, since they are not rendered as references either.
+func References(md string) []string {
+
+	doc := parser.NewWithExtensions(parser.CommonExtensions).Parse([]byte(md))
+
+	codes := []string{}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch v := node.(type) {
+		case *ast.Link:
+			if code, found := strings.CutPrefix(string(v.Destination), "nostr:"); found {
+				codes = append(codes, code)
+			}
+		case *ast.Text:
+			for _, m := range bareReference.FindAllSubmatch(v.Literal, -1) {
+				codes = append(codes, string(m[1]))
+			}
+		}
+		return ast.GoToNext
+	})
+
+	return codes
+}
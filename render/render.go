@@ -0,0 +1,238 @@
+// Package render converts the markdown content of nostr events into HTML that
+// is safe to embed in our pages.
+package render
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+type Options struct {
+	// Open external links in a new tab.
+	HrefTargetBlank bool
+	// Only render the text of links, for previews that are links themselves.
+	SkipLinks bool
+	// Used to display the profiles, notes and articles that are mentioned.
+	// Mentions are rendered as plain links when nil.
+	Fetcher Fetcher
+	// Number the lines of highlighted code blocks.
+	CodeLineNumbers bool
+	// Names of the providers whose urls are embedded as iframes, see Providers.
+	// Bare media urls are always embedded as players.
+	EmbedProviders []string
+}
+
+type Renderer struct {
+	opts   Options
+	policy *bluemonday.Policy
+}
+
+func New(opts Options) *Renderer {
+	return &Renderer{
+		opts:   opts,
+		policy: newPolicy(opts),
+	}
+}
+
+// Render markdown that does not belong to an event, so wikilinks cannot be resolved.
+func (r *Renderer) Render(ctx context.Context, md string) Document {
+	return r.render(ctx, &nostr.Event{}, md)
+}
+
+// Render the content of an event, with links resolved in the context of its author.
+func (r *Renderer) RenderEvent(ctx context.Context, e *nostr.Event) Document {
+	return r.render(ctx, e, e.Content)
+}
+
+// Render text that belongs to an event but is not its content, like the about
+// of a profile or the comment of a highlight. Emoji tags and wikilinks are
+// resolved in the context of the event.
+func (r *Renderer) RenderText(ctx context.Context, e *nostr.Event, text string) Document {
+	return r.render(ctx, e, text)
+}
+
+// Every article is user generated content, so the output is always sanitized.
+func (r *Renderer) render(ctx context.Context, e *nostr.Event, text string) Document {
+
+	author := e.PubKey
+	md := strings.ReplaceAll(text, "\u00A0", " ")
+
+	// The parser is stateful so it must be reinitialized every time
+	doc := parser.NewWithExtensions(
+		parser.CommonExtensions |
+			parser.AutoHeadingIDs |
+			parser.NoEmptyLineBeforeBlock |
+			parser.Footnotes,
+	).Parse([]byte(md))
+
+	rewriteReferences(doc)
+	parseCallouts(doc)
+	parseMath(doc)
+
+	mentions := parseMentions(doc)
+	wikilinks := parseWikiLinks(doc)
+	parseEmoji(doc, parseEmojiTags(e.Tags))
+	parseHashtags(doc)
+
+	d := document{
+		opts:   r.opts,
+		author: author,
+		refs:   r.fetchReferences(ctx, author, mentions, wikilinks),
+		media:  parseImeta(e.Tags),
+	}
+
+	flags := html.CommonFlags
+	if r.opts.HrefTargetBlank {
+		flags |= html.HrefTargetBlank
+	}
+
+	renderer := html.NewRenderer(html.RendererOptions{
+		Flags:          flags,
+		RenderNodeHook: d.renderNode,
+	})
+
+	output := markdown.Render(doc, renderer)
+
+	return Document{
+		HTML:    stripIframes(r.policy.Sanitize(string(output))),
+		Outline: outline(doc),
+	}
+}
+
+// State of a single render, shared by the node hooks.
+type document struct {
+	opts   Options
+	author string
+	refs   references
+	media  map[string]imeta
+}
+
+func (d document) renderNode(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+
+	switch v := node.(type) {
+	case *ast.Link:
+		// Skip the anchor tags, the link text is still rendered as a child node
+		if d.opts.SkipLinks {
+			return ast.GoToNext, true
+		}
+	case *ast.Paragraph:
+		// Replace the whole paragraph with a card, since cards are block elements
+		if m, ok := onlyChild(v).(*Mention); ok && !d.opts.SkipLinks && d.refs.event(m) != nil {
+			if entering {
+				d.refs.renderCard(w, m)
+			}
+			return ast.SkipChildren, true
+		}
+		// Bare urls on their own line are embedded, if they are media or from an allowed provider
+		if embed := d.embed(bareURL(onlyChild(v))); embed != "" && !d.opts.SkipLinks {
+			if entering {
+				io.WriteString(w, embed)
+			}
+			return ast.SkipChildren, true
+		}
+		if img, ok := onlyChild(v).(*ast.Image); ok && len(img.Title) > 0 {
+			if entering {
+				d.renderFigure(w, img)
+			}
+			return ast.SkipChildren, true
+		}
+	case *ast.Image:
+		if entering {
+			d.renderImage(w, v, true)
+		}
+		return ast.SkipChildren, true
+	case *Callout:
+		renderCallout(w, v, entering)
+		return ast.GoToNext, true
+	case *ast.Heading:
+		if !entering && v.HeadingID != "" && !d.opts.SkipLinks {
+			renderHeadingAnchor(w, v)
+			return ast.GoToNext, true
+		}
+	case *ast.CodeBlock:
+		if d.renderCodeBlock(w, v) {
+			return ast.GoToNext, true
+		}
+	case *ast.Math:
+		renderMath(w, string(v.Literal), false)
+		return ast.GoToNext, true
+	case *DisplayMath:
+		renderMath(w, string(v.Literal), true)
+		return ast.GoToNext, true
+	case *ast.MathBlock:
+		if entering {
+			renderMath(w, string(v.Literal), true)
+		}
+		return ast.GoToNext, true
+	case *WikiLink:
+		d.refs.renderWikiLink(w, d.author, v, d.opts.SkipLinks)
+		return ast.GoToNext, true
+	case *Emoji:
+		renderEmoji(w, v)
+		return ast.GoToNext, true
+	case *Hashtag:
+		renderHashtag(w, v, d.opts.SkipLinks)
+		return ast.GoToNext, true
+	case *Mention:
+		if d.opts.SkipLinks {
+			io.WriteString(w, template.HTMLEscapeString("nostr:"+v.Code))
+		} else {
+			d.refs.renderMention(w, v)
+		}
+		return ast.GoToNext, true
+	}
+
+	return ast.GoToNext, false
+}
+
+func newPolicy(opts Options) *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowStyling()
+	p.RequireNoFollowOnLinks(false)
+	// The sanitizer strips the target attribute the markdown renderer adds
+	p.AddTargetBlankToFullyQualifiedLinks(opts.HrefTargetBlank)
+	// Players for bare media urls
+	p.AllowElements("video", "audio", "source")
+	p.AllowAttrs("controls", "width").OnElements("video", "audio")
+	p.AllowAttrs("preload").Matching(regexp.MustCompile(`^metadata$`)).OnElements("video", "audio")
+	p.AllowAttrs("src", "width").OnElements("source")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^(audio|video)/[a-z0-9.+-]+$`)).OnElements("source")
+	// Embeds of allowed providers, all other iframes are stripped
+	p.AllowAttrs("src").Matching(embedSources(opts.EmbedProviders)).OnElements("iframe")
+	p.AllowAttrs("title").Matching(regexp.MustCompile(`^[a-z]+$`)).OnElements("iframe")
+	p.AllowAttrs("sandbox").Matching(regexp.MustCompile(`^` + iframeSandbox + `$`)).OnElements("iframe")
+	p.AllowAttrs("allow").Matching(regexp.MustCompile(`^` + iframeAllow + `$`)).OnElements("iframe")
+	p.AllowAttrs("allowfullscreen").OnElements("iframe")
+	p.AllowAttrs("loading").Matching(regexp.MustCompile(`^lazy$`)).OnElements("iframe")
+	p.AllowAttrs("referrerpolicy").Matching(regexp.MustCompile(`^strict-origin-when-cross-origin$`)).OnElements("iframe")
+	// MathML generated from $...$ and $$...$$
+	p.AllowNoAttrs().OnElements(
+		"math", "semantics", "annotation", "mrow", "mi", "mn", "mo", "mtext",
+		"mspace", "msub", "msup", "msubsup", "munder", "mover", "munderover",
+		"mfrac", "msqrt", "mroot", "mtable", "mtr", "mtd",
+	)
+	p.AllowAttrs("display").Matching(regexp.MustCompile(`^(block|inline)$`)).OnElements("math")
+	p.AllowAttrs("encoding").Matching(regexp.MustCompile(`^application/x-tex$`)).OnElements("annotation")
+	p.AllowAttrs("mathvariant").Matching(regexp.MustCompile(`^(normal|bold|italic|script)$`)).OnElements("mi")
+	p.AllowAttrs("stretchy", "largeop").Matching(regexp.MustCompile(`^true$`)).OnElements("mo")
+	p.AllowAttrs("accent").Matching(regexp.MustCompile(`^true$`)).OnElements("mover")
+	p.AllowAttrs("width").Matching(regexp.MustCompile(`^-?[0-9.]+em$`)).OnElements("mspace")
+	// Figures and lazy loading images, with the dimensions from imeta tags
+	p.AllowElements("figure", "figcaption")
+	p.AllowAttrs("loading").Matching(regexp.MustCompile(`^lazy$`)).OnElements("img")
+	// NIP-30 custom emoji show their shortcode as alt text
+	p.AllowAttrs("alt", "title").Matching(regexp.MustCompile(`^:[a-zA-Z0-9_]+:$`)).OnElements("img")
+	// Copy buttons of highlighted code blocks
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^button$`)).OnElements("button")
+	return p
+}
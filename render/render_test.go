@@ -0,0 +1,73 @@
+package render
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Articles are written by anyone, so nothing they contain may run scripts.
+func TestRenderSanitizes(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		text    string
+		banned  []string
+		allowed []string
+	}{
+		{
+			name:    "script",
+			text:    "hello <script>alert(1)</script> world",
+			banned:  []string{"<script", "alert(1)"},
+			allowed: []string{"hello", "world"},
+		},
+		{
+			name:    "javascript href",
+			text:    "[click](javascript:alert(1))",
+			banned:  []string{"javascript:", "<a "},
+			allowed: []string{"click"},
+		},
+		{
+			name:   "html javascript href",
+			text:   `<a href="javascript:alert(1)">click</a>`,
+			banned: []string{"javascript:"},
+		},
+		{
+			name:   "attribute in nostr link",
+			text:   `[x](nostr:" onmouseover="alert(1))`,
+			banned: []string{"onmouseover", "alert(1)"},
+		},
+		{
+			name:   "event handler",
+			text:   `<img src="https://example.com/a.png" onerror="alert(1)">`,
+			banned: []string{"onerror", "alert(1)"},
+		},
+		{
+			name:    "links stay",
+			text:    "[site](https://example.com)",
+			allowed: []string{`href="https://example.com"`},
+		},
+	}
+
+	r := New(Options{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			html := r.RenderText(context.Background(), &nostr.Event{}, tt.text).HTML
+
+			for _, s := range tt.banned {
+				if strings.Contains(html, s) {
+					t.Errorf("%q in %s", s, html)
+				}
+			}
+			for _, s := range tt.allowed {
+				if !strings.Contains(html, s) {
+					t.Errorf("%q not in %s", s, html)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// [[target]] or [[target|label]], where the target is the d tag or title of
+// another article by the same author.
+var wikilinkPattern = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|([^\[\]]+))?\]\]`)
+
+type WikiLink struct {
+	ast.Leaf
+	Target string
+	Label  string
+}
+
+func parseWikiLinks(doc ast.Node) []*WikiLink {
+
+	links := []*WikiLink{}
+
+	replaceText(doc, wikilinkPattern, func(match [][]byte) ast.Node {
+		link := &WikiLink{
+			Target: strings.TrimSpace(string(match[1])),
+			Label:  strings.TrimSpace(string(match[2])),
+		}
+		links = append(links, link)
+		return link
+	})
+
+	return links
+}
+
+// Find the article of the author with the target as d tag, or else as title.
+func (s references) resolve(author string, link *WikiLink) *nostr.Event {
+
+	var byTitle *nostr.Event
+
+	for _, e := range s.addresses {
+		if e.PubKey != author || e.Kind != nostr.KindArticle {
+			continue
+		}
+		if e.Tags.GetD() == link.Target {
+			return e
+		}
+		if title := e.Tags.GetFirst([]string{"title", ""}); title != nil && strings.EqualFold(title.Value(), link.Target) {
+			byTitle = e
+		}
+	}
+
+	return byTitle
+}
+
+// Unresolved links are marked, so authors notice them instead of seeing brackets.
+func (s references) renderWikiLink(w io.Writer, author string, link *WikiLink, skipLinks bool) {
+
+	e := s.resolve(author, link)
+
+	label := link.Label
+	if label == "" {
+		label = link.Target
+		if e != nil {
+			if title := e.Tags.GetFirst([]string{"title", ""}); title != nil && title.Value() != "" {
+				label = title.Value()
+			}
+		}
+	}
+	label = template.HTMLEscapeString(label)
+
+	switch {
+	case skipLinks:
+		io.WriteString(w, label)
+	case e == nil:
+		fmt.Fprintf(w, `<span class="wikilink unresolved" title="No article found for %s">%s</span>`, template.HTMLEscapeString(link.Target), label)
+	default:
+		naddr, _ := nip19.EncodeEntity(e.PubKey, e.Kind, e.Tags.GetD(), nil)
+		fmt.Fprintf(w, `<a class="wikilink" href="%s">%s</a>`, template.HTMLEscapeString(ReferenceURL(naddr)), label)
+	}
+}
+
+// Targets of all wikilinks in markdown content, in order of appearance.
+func WikiLinks(md string) []string {
+
+	doc := parser.NewWithExtensions(parser.CommonExtensions).Parse([]byte(md))
+
+	targets := []string{}
+	for _, link := range parseWikiLinks(doc) {
+		targets = append(targets, link.Target)
+	}
+
+	return targets
+}
@@ -0,0 +1,146 @@
+package notezero
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func isReplaceable(e *nostr.Event) bool {
+	return e.Kind == 0 || e.Kind == 3 || (e.Kind >= 10000 && e.Kind < 20000)
+}
+
+// Every version of a replaceable event shares the key, which is empty for
+// regular events.
+// 1. Replaceable events by kind and pubkey
+// 2. Parameterized replaceable events by kind, pubkey and d tag
+func replaceableKey(e *nostr.Event) string {
+	switch {
+	case isReplaceable(e):
+		return fmt.Sprintf("%d:%s", e.Kind, e.PubKey)
+	case isAddressable(e):
+		return eventAddress(e)
+	}
+	return ""
+}
+
+// Filter for every version of the event in the store.
+func versionsFilter(e *nostr.Event) nostr.Filter {
+	f := nostr.Filter{
+		Kinds:   []int{e.Kind},
+		Authors: []string{e.PubKey},
+	}
+	if isAddressable(e) {
+		f.Tags = nostr.TagMap{"d": []string{e.Tags.GetD()}}
+	}
+	return f
+}
+
+// NIP-01: the newest created_at wins, and on a tie the lowest id.
+func isNewer(a, b *nostr.Event) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt > b.CreatedAt
+	}
+	return a.ID < b.ID
+}
+
+// Only keep the newest version of every replaceable event, in the order they
+// were given. Regular events are all kept.
+func newestVersions(events []*nostr.Event) (newest []*nostr.Event, stale []*nostr.Event) {
+
+	latest := map[string]*nostr.Event{}
+	for _, e := range events {
+		key := replaceableKey(e)
+		if key == "" {
+			continue
+		}
+		if current, ok := latest[key]; !ok || isNewer(e, current) {
+			latest[key] = e
+		}
+	}
+
+	for _, e := range events {
+		key := replaceableKey(e)
+		if key == "" || latest[key] == e {
+			newest = append(newest, e)
+		} else {
+			stale = append(stale, e)
+		}
+	}
+
+	return newest, stale
+}
+
+// Store a replaceable event, unless a newer version is already stored. All
+// older versions are removed, not only the first one found.
+// False if the event is stale or already stored.
+func (s eventService) replace(ctx context.Context, e *nostr.Event) (bool, error) {
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	versions, err := wdb.QuerySync(ctx, versionsFilter(e))
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range versions {
+		if v.ID == e.ID {
+			return false, nil
+		}
+		if isNewer(v, e) {
+			return false, nil
+		}
+	}
+
+	for _, v := range versions {
+		err := s.deleteEvent(ctx, v)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = s.db.SaveEvent(ctx, e)
+	if err != nil && err != eventstore.ErrDupEvent {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Remove the stale versions that ended up in the store anyway, for example
+// from before the store reconciled versions.
+func (s eventService) discardStale(ctx context.Context, events []*nostr.Event) ([]*nostr.Event, error) {
+
+	newest, stale := newestVersions(events)
+
+	for _, e := range stale {
+		err := s.deleteEvent(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newest, nil
+}
+
+// Send the newest version to the relays that served a stale one. Relays are
+// free to refuse it, this is only a courtesy.
+func (s eventService) republish(e *nostr.Event, relays []string) {
+	for _, url := range relays {
+		go func(url string) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			r, err := nostr.RelayConnect(ctx, url)
+			if err != nil {
+				return
+			}
+			defer r.Close()
+
+			r.Publish(ctx, *e)
+		}(url)
+	}
+}
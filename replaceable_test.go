@@ -0,0 +1,96 @@
+package notezero
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestNewestVersions(t *testing.T) {
+
+	now := nostr.Now()
+
+	profile := &nostr.Event{ID: "b", PubKey: "pk", Kind: 0, CreatedAt: now - 10}
+	newProfile := &nostr.Event{ID: "c", PubKey: "pk", Kind: 0, CreatedAt: now}
+	// Same created_at, the lowest id wins
+	tie := &nostr.Event{ID: "a", PubKey: "pk", Kind: 0, CreatedAt: now}
+
+	article := &nostr.Event{ID: "d", PubKey: "pk", Kind: 30023, Tags: nostr.Tags{{"d", "one"}}, CreatedAt: now}
+	oldArticle := &nostr.Event{ID: "e", PubKey: "pk", Kind: 30023, Tags: nostr.Tags{{"d", "one"}}, CreatedAt: now - 10}
+	otherArticle := &nostr.Event{ID: "f", PubKey: "pk", Kind: 30023, Tags: nostr.Tags{{"d", "two"}}, CreatedAt: now - 20}
+
+	note := &nostr.Event{ID: "g", PubKey: "pk", Kind: 1, CreatedAt: now - 30}
+	otherNote := &nostr.Event{ID: "h", PubKey: "pk", Kind: 1, CreatedAt: now - 40}
+
+	newest, stale := newestVersions([]*nostr.Event{profile, newProfile, oldArticle, note, article, tie, otherArticle, otherNote})
+
+	if want := []*nostr.Event{note, article, tie, otherArticle, otherNote}; !slices.Equal(newest, want) {
+		t.Errorf("newest: got %v, want %v", newest, want)
+	}
+	if want := []*nostr.Event{profile, newProfile, oldArticle}; !slices.Equal(stale, want) {
+		t.Errorf("stale: got %v, want %v", stale, want)
+	}
+}
+
+func TestReplace(t *testing.T) {
+
+	ctx := context.Background()
+	now := nostr.Now()
+	sk := nostr.GeneratePrivateKey()
+
+	version := func(createdAt nostr.Timestamp, content string) *nostr.Event {
+		return signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "one"}}, Content: content, CreatedAt: createdAt})
+	}
+	v1, v2, v3 := version(now-20, "one"), version(now-10, "two"), version(now, "three")
+	other := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "other"}}, CreatedAt: now - 30})
+
+	s := newTestService(t)
+
+	// Versions that ended up in the store side by side, like from an older
+	// version of the store
+	for _, e := range []*nostr.Event{v1, v2, other} {
+		err := s.db.SaveEvent(ctx, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		event *nostr.Event
+		want  bool
+	}{
+		{name: "stale", event: v1},
+		{name: "newer", event: v3, want: true},
+		{name: "already stored", event: v3},
+		{name: "replaced", event: v2},
+	}
+
+	for _, tt := range tests {
+		got, err := s.replace(ctx, tt.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	events, err := eventstore.RelayWrapper{Store: s.db}.QuerySync(ctx, nostr.Filter{Kinds: []int{nostr.KindArticle}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	slices.Sort(ids)
+	want := []string{v3.ID, other.ID}
+	slices.Sort(want)
+	if !slices.Equal(ids, want) {
+		t.Fatalf("stored %v, want only the newest version and the other article", ids)
+	}
+}
@@ -0,0 +1,330 @@
+package notezero
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Events evicted by the retention policy, served on /debug/vars.
+var retentionStats = expvar.NewMap("retention")
+
+// Reads within this long of the last recorded one are not recorded again,
+// so serving a popular article does not write on every request.
+const accessResolution = time.Hour
+
+// Events per query when backfilling access records, within the limit of
+// every backend.
+const backfillPage = 500
+
+// How long stored events are kept. The zero value keeps everything.
+type Retention struct {
+	// Events that were not read for this long are evicted, never when zero.
+	MaxAge time.Duration
+	// While the events take more space, the least recently read ones are
+	// evicted, no limit when zero. Measured as the size of the event JSON,
+	// not on disk.
+	MaxBytes int64
+	// Authors whose events, and the events that refer to them, are never
+	// evicted, as hex public keys.
+	Pinned []string
+}
+
+func (r Retention) enabled() bool {
+	return r.MaxAge > 0 || r.MaxBytes > 0
+}
+
+// Deletions are never evicted, or the events they delete would be stored
+// again from the relays. Neither are the events of pinned authors, nor the
+// ones that refer to them, like the highlights of their articles.
+func (r Retention) keeps(rec accessRecord) bool {
+	if rec.Kind == nostr.KindDeletion || slices.Contains(r.Pinned, rec.PubKey) {
+		return true
+	}
+	for _, pk := range rec.Tagged {
+		if slices.Contains(r.Pinned, pk) {
+			return true
+		}
+	}
+	return false
+}
+
+// What an eviction run removed.
+type RetentionReport struct {
+	Evicted int
+	Bytes   int64
+}
+
+type accessRecord struct {
+	At     nostr.Timestamp `json:"at"`
+	PubKey string          `json:"pubkey"`
+	Kind   int             `json:"kind"`
+	Size   int64           `json:"size"`
+	// Authors the event refers to with "p" and "a" tags
+	Tagged []string `json:"tagged,omitempty"`
+}
+
+func newAccessRecord(e *nostr.Event, at nostr.Timestamp) accessRecord {
+
+	rec := accessRecord{
+		At:     at,
+		PubKey: e.PubKey,
+		Kind:   e.Kind,
+		Size:   int64(len(e.String())),
+	}
+
+	for _, t := range e.Tags {
+		switch t.Key() {
+		case "p":
+			rec.Tagged = append(rec.Tagged, t.Value())
+		case "a":
+			if parts := addressParts(t.Value()); len(parts) == 3 {
+				rec.Tagged = append(rec.Tagged, parts[1])
+			}
+		}
+	}
+
+	return rec
+}
+
+func accessKey(id string) string {
+	return cacheKey(nsAccess, id)
+}
+
+// Evict stored events according to the retention policy.
+func (s eventService) WithRetention(r Retention) eventService {
+	s.retention = r
+	return s
+}
+
+// Record that the events were read, or stored when they come from the relays.
+// Recorded without a retention policy too, so one set later knows what was
+// read.
+func (s eventService) touch(events ...*nostr.Event) {
+
+	now := nostr.Now()
+
+	for _, e := range events {
+
+		var rec accessRecord
+		found, err := getJSON(s.cache, accessKey(e.ID), &rec)
+		if err == nil && found && time.Duration(now-rec.At)*time.Second < accessResolution {
+			continue
+		}
+
+		setJSON(s.cache, accessKey(e.ID), newAccessRecord(e, now), 0)
+	}
+}
+
+// Remove what was derived from a stored event, once it is deleted.
+func (s eventService) unindex(e *nostr.Event) error {
+
+	keys := []string{accessKey(e.ID)}
+
+	if slices.Contains(backlinkKinds, e.Kind) {
+		for _, address := range referencedAddresses(e) {
+			keys = append(keys, backlinkKey(address, e.ID))
+		}
+	}
+
+	if exp := expiration(e); exp != 0 {
+		keys = append(keys, expirationKey(exp, e.ID))
+	}
+
+	for _, key := range keys {
+		err := s.cache.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Pages that showed an evicted event wait for the relays again, instead of
+// serving the local store without it.
+func (s eventService) forgetSync(e *nostr.Event) error {
+
+	keys := []string{}
+
+	for _, t := range e.Tags {
+		switch {
+		case t.Key() == "a" && e.Kind == 9802:
+			keys = append(keys, cacheKey(nsHighlights, addressParts(t.Value())...))
+		case t.Key() == "a" && slices.Contains(backlinkKinds, e.Kind):
+			keys = append(keys, cacheKey(nsBacklinks, addressParts(t.Value())...))
+		case t.Key() == "t" && e.Kind == nostr.KindArticle:
+			keys = append(keys, cacheKey(nsTags, strings.ToLower(t.Value())))
+		}
+	}
+
+	for _, key := range keys {
+		err := s.cache.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Evict the events that were not read within the maximum age, then the least
+// recently read ones until the store is within its size. Deletions, and events
+// of or about pinned authors, are kept.
+func (s eventService) Collect(ctx context.Context) (RetentionReport, error) {
+
+	report := RetentionReport{}
+
+	if !s.retention.enabled() {
+		return report, nil
+	}
+
+	err := s.backfillAccess(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	prefix := cacheKey(nsAccess)
+
+	keys, err := s.cache.Keys(prefix)
+	if err != nil {
+		return report, err
+	}
+
+	type candidate struct {
+		id string
+		accessRecord
+	}
+
+	candidates := []candidate{}
+	var total int64
+
+	for _, key := range keys {
+		var rec accessRecord
+		found, err := getJSON(s.cache, key, &rec)
+		if err != nil {
+			return report, err
+		}
+		if !found {
+			continue
+		}
+		total += rec.Size
+		if s.retention.keeps(rec) {
+			continue
+		}
+		candidates = append(candidates, candidate{strings.TrimPrefix(key, prefix), rec})
+	}
+
+	// Least recently read first
+	slices.SortFunc(candidates, func(a, b candidate) int { return int(a.At - b.At) })
+
+	cutoff := nostr.Timestamp(time.Now().Add(-s.retention.MaxAge).Unix())
+
+	evict := []string{}
+	for _, c := range candidates {
+		tooOld := s.retention.MaxAge > 0 && c.At < cutoff
+		tooBig := s.retention.MaxBytes > 0 && total > s.retention.MaxBytes
+		if !tooOld && !tooBig {
+			break
+		}
+		evict = append(evict, c.id)
+		total -= c.Size
+		report.Bytes += c.Size
+	}
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	for len(evict) != 0 {
+
+		batch := evict[:min(len(evict), 100)]
+		evict = evict[len(batch):]
+
+		events, err := wdb.QuerySync(ctx, nostr.Filter{IDs: batch})
+		if err != nil {
+			return report, err
+		}
+
+		for _, e := range events {
+			err := s.deleteEvent(ctx, e)
+			if err != nil {
+				return report, err
+			}
+			err = s.forgetSync(e)
+			if err != nil {
+				return report, err
+			}
+			report.Evicted++
+		}
+
+		// Records of events that are already gone
+		for _, id := range batch {
+			err := s.cache.Delete(accessKey(id))
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+
+	retentionStats.Add("evicted_events", int64(report.Evicted))
+	retentionStats.Add("evicted_bytes", report.Bytes)
+
+	return report, nil
+}
+
+// Give the events stored without an access record, like by a version that
+// did not record them, one as if they were read when they were created. Done
+// once per store.
+func (s eventService) backfillAccess(ctx context.Context) error {
+
+	key := cacheKey(nsRetention, "backfill")
+
+	_, done, err := s.lastSync(key)
+	if err != nil || done {
+		return err
+	}
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	until := nostr.Now()
+	for {
+		events, err := wdb.QuerySync(ctx, nostr.Filter{Until: &until, Limit: backfillPage})
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			_, found, err := s.cache.Get(accessKey(e.ID))
+			if err != nil {
+				return err
+			}
+			if found {
+				continue
+			}
+			err = setJSON(s.cache, accessKey(e.ID), newAccessRecord(e, e.CreatedAt), 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(events) < backfillPage {
+			break
+		}
+
+		// Events of the same second can span pages, so the next page starts
+		// at the oldest one again. Filters cannot page within a second, and
+		// skipping past it would leave its other events without a record.
+		oldest := events[len(events)-1].CreatedAt
+		if oldest == until {
+			return fmt.Errorf("more than %d events at %d, cannot record their access", backfillPage, oldest)
+		}
+		until = oldest
+	}
+
+	return setJSON(s.cache, key, nostr.Now(), 0)
+}
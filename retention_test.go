@@ -0,0 +1,262 @@
+package notezero
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func stored(t *testing.T, s eventService, e *nostr.Event) bool {
+	t.Helper()
+	events, err := eventstore.RelayWrapper{Store: s.db}.QuerySync(context.Background(), nostr.Filter{IDs: []string{e.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(events) == 1
+}
+
+func TestAccessRecordedWithoutRetention(t *testing.T) {
+
+	s := newTestService(t)
+	e := signed(t, nostr.GeneratePrivateKey(), nostr.Event{Kind: nostr.KindTextNote})
+
+	err := s.save(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec accessRecord
+	found, err := getJSON(s.cache, accessKey(e.ID), &rec)
+	if err != nil || !found {
+		t.Fatalf("no access record: %v", err)
+	}
+	if rec.PubKey != e.PubKey || rec.Size != int64(len(e.String())) {
+		t.Fatalf("got %+v", rec)
+	}
+}
+
+func TestCollect(t *testing.T) {
+
+	ctx := context.Background()
+	now := nostr.Now()
+	hour := nostr.Timestamp(time.Hour.Seconds())
+
+	sk := nostr.GeneratePrivateKey()
+	pinnedSk := nostr.GeneratePrivateKey()
+	pinned, _ := nostr.GetPublicKey(pinnedSk)
+
+	note := func(sk string, i int) *nostr.Event {
+		return signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, CreatedAt: now - nostr.Timestamp(i)})
+	}
+
+	// Read an hour apart, the first one longest ago
+	a, b, c := note(sk, 1), note(sk, 2), note(sk, 3)
+	p := note(pinnedSk, 4)
+
+	size := int64(len(a.String()))
+
+	tests := []struct {
+		name      string
+		retention Retention
+		evicted   []*nostr.Event
+	}{
+		{
+			name:      "max age",
+			retention: Retention{MaxAge: 90 * time.Minute, Pinned: []string{pinned}},
+			evicted:   []*nostr.Event{a, b},
+		},
+		{
+			name:      "max bytes",
+			retention: Retention{MaxBytes: 3 * size, Pinned: []string{pinned}},
+			evicted:   []*nostr.Event{a},
+		},
+		{
+			name:      "not pinned",
+			retention: Retention{MaxAge: 90 * time.Minute},
+			evicted:   []*nostr.Event{p, a, b},
+		},
+		{
+			name:      "disabled",
+			retention: Retention{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := newTestService(t).WithRetention(tt.retention)
+
+			for i, e := range []*nostr.Event{a, b, c, p} {
+				err := s.save(ctx, e)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = setJSON(s.cache, accessKey(e.ID), accessRecord{
+					At:     now - hour*nostr.Timestamp(3-i),
+					PubKey: e.PubKey,
+					Size:   int64(len(e.String())),
+				}, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The pinned event was read longest ago
+			setJSON(s.cache, accessKey(p.ID), accessRecord{At: now - 10*hour, PubKey: p.PubKey, Size: size}, 0)
+
+			report, err := s.Collect(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Evicted != len(tt.evicted) {
+				t.Fatalf("evicted %d events, want %d", report.Evicted, len(tt.evicted))
+			}
+
+			for _, e := range []*nostr.Event{a, b, c, p} {
+				want := true
+				for _, evicted := range tt.evicted {
+					if evicted == e {
+						want = false
+					}
+				}
+				if got := stored(t, s, e); got != want {
+					t.Errorf("%s stored: got %v, want %v", e.ID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectKeeps(t *testing.T) {
+
+	ctx := context.Background()
+	old := nostr.Now() - 2*24*60*60
+
+	sk := nostr.GeneratePrivateKey()
+	pinned, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	article := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "gone"}}, CreatedAt: old})
+	deletion := signed(t, sk, nostr.Event{Kind: nostr.KindDeletion, Tags: nostr.Tags{{"e", article.ID}}, CreatedAt: old + 1})
+	highlight := signed(t, sk, nostr.Event{Kind: 9802, Tags: nostr.Tags{{"a", "30023:" + pinned + ":intro"}}, CreatedAt: old})
+	mention := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, Tags: nostr.Tags{{"p", pinned}}, CreatedAt: old})
+	other := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, Content: "other", CreatedAt: old})
+
+	s := newTestService(t).WithRetention(Retention{MaxAge: 24 * time.Hour, Pinned: []string{pinned}})
+
+	for _, e := range []*nostr.Event{article, deletion, highlight, mention, other} {
+		err := s.save(ctx, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = setJSON(s.cache, accessKey(e.ID), newAccessRecord(e, old), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := s.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Evicted != 1 || stored(t, s, other) {
+		t.Fatalf("evicted %d events, want only the unrelated note", report.Evicted)
+	}
+	for _, e := range []*nostr.Event{deletion, highlight, mention} {
+		if !stored(t, s, e) {
+			t.Errorf("kind %d event was evicted", e.Kind)
+		}
+	}
+
+	// The deletion still applies to the article when a relay sends it again
+	err = s.save(ctx, article)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored(t, s, article) {
+		t.Fatal("deleted article was stored again")
+	}
+}
+
+// Events without an access record, like those stored by an older version,
+// count as read when they were created.
+func TestCollectBackfillsAccess(t *testing.T) {
+
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	old := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now() - 2*24*60*60})
+	recent := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote})
+
+	s := newTestService(t).WithRetention(Retention{MaxAge: 24 * time.Hour})
+
+	for _, e := range []*nostr.Event{old, recent} {
+		err := s.db.SaveEvent(ctx, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := s.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Evicted != 1 || stored(t, s, old) || !stored(t, s, recent) {
+		t.Fatalf("evicted %d, want only the old event", report.Evicted)
+	}
+}
+
+// Pages end within a second, so the next one starts at that second again.
+func TestBackfillAccessPages(t *testing.T) {
+
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+	old := nostr.Now() - 2*24*60*60
+
+	s := newTestService(t).WithRetention(Retention{MaxAge: 24 * time.Hour})
+
+	// 300 events a second, so the first page ends within the second second
+	events := []*nostr.Event{}
+	for i := range 3 * 300 {
+		e := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, Content: strconv.Itoa(i), CreatedAt: old - nostr.Timestamp(i/300)})
+		err := s.db.SaveEvent(ctx, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+
+	report, err := s.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Evicted != len(events) {
+		t.Fatalf("evicted %d events, want %d", report.Evicted, len(events))
+	}
+}
+
+// Filters cannot page within a second, so backfilling fails instead of
+// leaving events without an access record.
+func TestBackfillAccessFullSecond(t *testing.T) {
+
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+	old := nostr.Now() - 2*24*60*60
+
+	s := newTestService(t).WithRetention(Retention{MaxAge: 24 * time.Hour})
+
+	for i := range backfillPage + 1 {
+		e := signed(t, sk, nostr.Event{Kind: nostr.KindTextNote, Content: strconv.Itoa(i), CreatedAt: old})
+		err := s.db.SaveEvent(ctx, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := s.Collect(ctx)
+	if err == nil {
+		t.Fatal("backfilled more events of one second than fit a page")
+	}
+}
@@ -2,31 +2,65 @@ package notezero
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
 	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/dextryz/notezero/badger"
 	"github.com/fiatjaf/eventstore"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
+	"golang.org/x/sync/singleflight"
 )
 
+var DefaultRelays = []string{
+	"wss://relay.damus.io/",
+	"wss://nostr-01.yakihonne.com",
+	// "wss://nostr-02.yakihonne.com",
+	"wss://relay.highlighter.com/",
+	//"wss://relay.f7z.io",
+	"wss://nos.lol",
+}
+
 type eventService struct {
 	db     eventstore.Store
-	cache  *badger.Cache
+	cache  Cache
 	relays []string
+	// Send the newest version of a replaceable event to relays with a stale one
+	republishStale bool
+	mem            *MemoryCache
+	retention      Retention
+	// Background refreshes in flight, by sync key
+	refreshes *singleflight.Group
 }
 
-func NewEventService(db eventstore.Store, cache *badger.Cache, relays []string) eventService {
+func NewEventService(db eventstore.Store, cache Cache, relays []string) eventService {
 	return eventService{
-		db:     db,
-		cache:  cache,
-		relays: relays,
+		db:        db,
+		cache:     cache,
+		relays:    relays,
+		refreshes: &singleflight.Group{},
 	}
 }
 
+// Relays that serve a stale version of a replaceable event are sent the
+// newest version.
+func (s eventService) WithRepublish(republish bool) eventService {
+	s.republishStale = republish
+	return s
+}
+
+// Keep decoded events in memory, in front of the eventstore.
+func (s eventService) WithMemoryCache(mem *MemoryCache) eventService {
+	s.mem = mem
+	return s
+}
+
 // 1. Check if the event is in the cache
 // 2. If not, request event from the set of relays
 func (s eventService) RequestEvent(ctx context.Context, code string) (*nostr.Event, error) {
@@ -42,8 +76,12 @@ func (s eventService) RequestEvent(ctx context.Context, code string) (*nostr.Eve
 
 	var filter nostr.Filter
 
+	// Address of the event, used as key in the memory cache
+	var key string
+
 	switch v := data.(type) {
 	case nostr.EntityPointer:
+		key = fmt.Sprintf("%d:%s:%s", v.Kind, v.PublicKey, v.Identifier)
 		filter.Authors = []string{v.PublicKey}
 		filter.Tags = nostr.TagMap{
 			"d": []string{v.Identifier},
@@ -53,6 +91,7 @@ func (s eventService) RequestEvent(ctx context.Context, code string) (*nostr.Eve
 		}
 	case string:
 		if prefix == "npub" {
+			key = fmt.Sprintf("%d:%s", nostr.KindProfileMetadata, v)
 			filter.Authors = []string{v}
 			filter.Kinds = []int{0}
 		}
@@ -60,23 +99,61 @@ func (s eventService) RequestEvent(ctx context.Context, code string) (*nostr.Eve
 		return nil, fmt.Errorf("code type not supported: %s", code)
 	}
 
+	// An event deleted after it was cached is removed in the background, and
+	// no longer served afterwards
+	checkDeletions := func(e *nostr.Event) {
+		s.refresh(cacheKey(nsDeletions, e.ID), func(ctx context.Context) {
+			s.fetchDeletions(ctx, []*nostr.Event{e})
+		})
+	}
+
+	if key != "" {
+		if e, ok := s.mem.getEvent(key); ok {
+			checkDeletions(e)
+			s.touch(e)
+			return e, nil
+		}
+	}
+
 	// Try to fetch in our internal eventstore (cache) first
 	events, err := wdb.QuerySync(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 	if len(events) != 0 {
+		events, err = s.discardStale(ctx, events)
+		if err != nil {
+			return nil, err
+		}
+		s.mem.putEvent(events[0])
+		checkDeletions(events[0])
+		s.touch(events[0])
 		return events[0], nil
 	}
 
 	// No events found in cache, request relays and publish to cache
 	events = s.queryRelays(ctx, filter)
 	for _, e := range events {
-		err := wdb.Publish(ctx, *e)
+		err := s.save(ctx, e)
 		if err != nil {
 			return nil, err
 		}
 	}
+	s.fetchDeletions(ctx, events)
+
+	// Query the cache again, since the events could have been deleted
+	events, err = wdb.QuerySync(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("event not found: %s", code)
+	}
+	events, err = s.discardStale(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+	s.mem.putEvent(events[0])
 
 	return events[0], nil
 }
@@ -103,20 +180,38 @@ func (s eventService) AuthorArticles(ctx context.Context, npub string) ([]*nostr
 		return nil, err
 	}
 	if len(events) != 0 {
+		// Keep up with articles the author deleted since they were cached
+		deleted := slices.Clone(events)
+		s.refresh(cacheKey(nsDeletions, pk.(string)), func(ctx context.Context) {
+			s.fetchDeletions(ctx, deleted)
+		})
+		events, err = s.discardStale(ctx, events)
+		if err != nil {
+			return nil, err
+		}
+		sortByPublishedAt(events)
+		s.touch(events...)
 		return events, nil
 	}
 
 	// No events found in cache, request relays and publish to cache
 	events = s.queryRelays(ctx, filter)
 	for _, e := range events {
-		err := wdb.Publish(ctx, *e)
+		err := s.save(ctx, e)
 		if err != nil {
 			return nil, err
 		}
 	}
+	s.fetchDeletions(ctx, events)
+
+	// Query the cache again, since articles could have been deleted
+	events, err = wdb.QuerySync(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
 
 	// sort before returning
-	slices.SortFunc(events, func(a, b *nostr.Event) int { return int(b.CreatedAt - a.CreatedAt) })
+	sortByPublishedAt(events)
 
 	return events, nil
 }
@@ -125,12 +220,12 @@ func (s eventService) ArticleHighlights(ctx context.Context, kind int, pubkey, i
 
 	wdb := eventstore.RelayWrapper{Store: s.db}
 
-	pool := nostr.NewSimplePool(context.Background())
-
 	// 2. Article is cached, so pull highlights
 
 	tag := fmt.Sprintf("%d:%s:%s", kind, pubkey, identifier)
 
+	syncKey := cacheKey(nsHighlights, strconv.Itoa(kind), pubkey, identifier)
+
 	filter := nostr.Filter{
 		Kinds: []int{9802},
 		Tags: nostr.TagMap{
@@ -141,38 +236,41 @@ func (s eventService) ArticleHighlights(ctx context.Context, kind int, pubkey, i
 
 	var lastNotes []*nostr.Event
 
-	// fetch from external relays asynchronously
-	external := make(chan []*nostr.Event)
-	go func() {
-		notes := make([]*nostr.Event, 0, filter.Limit)
-		defer func() {
-			external <- notes
-		}()
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-		defer cancel()
-		ch := pool.SubManyEose(ctx, s.relays, nostr.Filters{filter})
-		for {
-			select {
-			case ie, more := <-ch:
-				if !more {
-					return
-				}
-				notes = append(notes, ie.Event)
-				s.db.SaveEvent(ctx, ie.Event)
-				s.cache.Set(identifier, []byte{})
-			case <-ctx.Done():
-				return
-			}
+	fetch := func(ctx context.Context) {
+		for ie := range s.subscribe(ctx, nostr.Filters{filter}) {
+			s.save(ctx, ie.Event)
 		}
-	}()
+	}
 
 	// fetch from local store if available
-	if _, found := s.cache.Get(identifier); found {
+	_, synced, err := s.lastSync(syncKey)
+	if err != nil {
+		return nil, err
+	}
+	if synced {
 		lastNotes, _ = wdb.QuerySync(ctx, filter)
+		// New highlights, and the ones that were deleted by the highlighter since
+		notes := slices.Clone(lastNotes)
+		s.refresh(syncKey, func(ctx context.Context) {
+			fetch(ctx)
+			s.fetchDeletions(ctx, notes)
+		})
 	} else {
-		// if we didn't get enough notes (or if we didn't even query the local store), wait for the external relays
-		lastNotes = <-external
-		s.cache.Set(identifier, []byte{})
+		// if we didn't even query the local store, wait for the external relays
+		fetchCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+		fetch(fetchCtx)
+		cancel()
+
+		err := s.setLastSync(syncKey)
+		if err != nil {
+			return nil, err
+		}
+
+		lastNotes, _ = wdb.QuerySync(ctx, filter)
+
+		// Query the cache again, without the highlights that were deleted
+		s.fetchDeletions(ctx, lastNotes)
+		lastNotes, _ = wdb.QuerySync(ctx, filter)
 
 		// 		tags := nostr.Tags{
 		// 			{"a", tag},
@@ -196,23 +294,384 @@ func (s eventService) ArticleHighlights(ctx context.Context, kind int, pubkey, i
 		// 		lastNotes = append(lastNotes, &e)
 	}
 
+	s.touch(lastNotes...)
+
 	return lastNotes, nil
 }
 
+// Articles from any author with the hashtag.
+// Only wait for the relays the first time, afterwards refresh in the background.
+func (s eventService) TagArticles(ctx context.Context, tag string) ([]*nostr.Event, error) {
+
+	filter := nostr.Filter{
+		Kinds: []int{nostr.KindArticle},
+		Tags: nostr.TagMap{
+			"t": []string{strings.ToLower(tag)},
+		},
+		Limit: 100,
+	}
+
+	syncKey := cacheKey(nsTags, strings.ToLower(tag))
+
+	fetch := func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		for ie := range s.subscribe(ctx, nostr.Filters{filter}) {
+			s.save(ctx, ie.Event)
+		}
+		s.setLastSync(syncKey)
+	}
+
+	_, synced, err := s.lastSync(syncKey)
+	if err != nil {
+		return nil, err
+	}
+	if synced {
+		s.refresh(syncKey, fetch)
+	} else {
+		fetch(ctx)
+	}
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	events, err := wdb.QuerySync(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sortByPublishedAt(events)
+	s.touch(events...)
+
+	return events, nil
+}
+
+// Articles and notes that refer to the article.
+// 1. Request the events with an "a" tag of the article from the relays
+// 2. Every saved event is indexed, so the local index includes events found before
+func (s eventService) ArticleBacklinks(ctx context.Context, kind int, pubkey, identifier string) ([]*nostr.Event, error) {
+
+	address := fmt.Sprintf("%d:%s:%s", kind, pubkey, identifier)
+
+	syncKey := cacheKey(nsBacklinks, strconv.Itoa(kind), pubkey, identifier)
+
+	filter := nostr.Filter{
+		Kinds: backlinkKinds,
+		Tags: nostr.TagMap{
+			"a": []string{address},
+		},
+		Limit: 500,
+	}
+
+	fetch := func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		for ie := range s.subscribe(ctx, nostr.Filters{filter}) {
+			s.save(ctx, ie.Event)
+		}
+		s.setLastSync(syncKey)
+	}
+
+	// Only wait for the relays the first time, afterwards refresh in the background
+	_, synced, err := s.lastSync(syncKey)
+	if err != nil {
+		return nil, err
+	}
+	if synced {
+		s.refresh(syncKey, fetch)
+	} else {
+		fetch(ctx)
+	}
+
+	ids, err := s.backlinks(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []*nostr.Event{}, nil
+	}
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	events, err := wdb.QuerySync(ctx, nostr.Filter{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(events, func(a, b *nostr.Event) int { return int(b.CreatedAt - a.CreatedAt) })
+	s.touch(events...)
+
+	return events, nil
+}
+
+// Used to resolve the references in an article.
+// 1. Query each filter in our internal eventstore (cache)
+// 2. Send what the store is missing to the relays as a single subscription
+func (s eventService) FetchEvents(ctx context.Context, filters nostr.Filters) ([]*nostr.Event, error) {
+
+	wdb := eventstore.RelayWrapper{Store: s.db}
+
+	events := []*nostr.Event{}
+	missing := nostr.Filters{}
+
+	// Sync keys of the lists that are requested
+	lists := []string{}
+
+	for _, filter := range filters {
+
+		// Events requested by id are often already in memory
+		if onlyIDs(filter) {
+			remaining := []string{}
+			for _, id := range filter.IDs {
+				if e, ok := s.mem.getEvent(id); ok {
+					s.touch(e)
+					events = append(events, e)
+				} else {
+					remaining = append(remaining, id)
+				}
+			}
+			if len(remaining) == 0 {
+				continue
+			}
+			filter.IDs = remaining
+		}
+
+		cached, err := wdb.QuerySync(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		rest, ok := remainder(filter, cached)
+
+		// Lists, like the articles of an author for wikilinks, can have more on
+		// the relays than in the store
+		if isList(filter) {
+			key := cacheKey(nsLists, filterHash(filter))
+			stale, err := s.stale(key)
+			if err != nil {
+				return nil, err
+			}
+			if stale {
+				ok = true
+				lists = append(lists, key)
+			}
+		}
+
+		if ok {
+			missing = append(missing, rest)
+		}
+		for _, e := range cached {
+			s.mem.putEvent(e)
+		}
+		s.touch(cached...)
+		events = append(events, cached...)
+	}
+
+	if len(missing) == 0 {
+		return events, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	// Lists already have some events from the store
+	seen := map[string]bool{}
+	for _, e := range events {
+		seen[e.ID] = true
+	}
+
+	for ie := range s.subscribe(ctx, missing) {
+		err := s.save(ctx, ie.Event)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[ie.Event.ID] {
+			seen[ie.Event.ID] = true
+			events = append(events, ie.Event)
+		}
+	}
+
+	for _, key := range lists {
+		err := s.setLastSync(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+func (s eventService) deleteEvent(ctx context.Context, e *nostr.Event) error {
+	s.mem.forgetEvent(e)
+	err := s.db.DeleteEvent(ctx, e)
+	if err != nil {
+		return err
+	}
+	return s.unindex(e)
+}
+
+// The part of the filter the store does not have, false if it has all of it.
+//  1. Events by id, and addresses by d tag, are missing if they were not found
+//  2. Replaceable events, like profiles, are missing for authors without one
+//  3. Anything else is only requested when the store has nothing
+func remainder(filter nostr.Filter, cached []*nostr.Event) (nostr.Filter, bool) {
+
+	switch {
+	case len(filter.IDs) != 0:
+		found := map[string]bool{}
+		for _, e := range cached {
+			found[e.ID] = true
+		}
+		filter.IDs = missingValues(filter.IDs, found)
+		return filter, len(filter.IDs) != 0
+
+	case len(filter.Tags["d"]) != 0:
+		found := map[string]bool{}
+		for _, e := range cached {
+			found[e.Tags.GetD()] = true
+		}
+		filter.Tags = maps.Clone(filter.Tags)
+		filter.Tags["d"] = missingValues(filter.Tags["d"], found)
+		return filter, len(filter.Tags["d"]) != 0
+
+	case len(filter.Authors) != 0 && onlyReplaceable(filter.Kinds):
+		found := map[string]bool{}
+		for _, e := range cached {
+			found[e.PubKey] = true
+		}
+		filter.Authors = missingValues(filter.Authors, found)
+		return filter, len(filter.Authors) != 0
+	}
+
+	return filter, len(cached) == 0
+}
+
+// Filters that are not answered completely by events the store has, see
+// remainder.
+func isList(f nostr.Filter) bool {
+	return len(f.IDs) == 0 && len(f.Tags["d"]) == 0 && !(len(f.Authors) != 0 && onlyReplaceable(f.Kinds))
+}
+
+func filterHash(f nostr.Filter) string {
+	hash := sha256.Sum256([]byte(f.String()))
+	return hex.EncodeToString(hash[:16])
+}
+
+func missingValues(values []string, found map[string]bool) []string {
+	missing := []string{}
+	for _, v := range values {
+		if !found[v] {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+// Kinds with a single event per author.
+func onlyReplaceable(kinds []int) bool {
+	if len(kinds) == 0 {
+		return false
+	}
+	for _, k := range kinds {
+		if k != nostr.KindProfileMetadata && k != nostr.KindContactList && (k < 10000 || k >= 20000) {
+			return false
+		}
+	}
+	return true
+}
+
+func onlyIDs(f nostr.Filter) bool {
+	return len(f.IDs) != 0 && len(f.Kinds) == 0 && len(f.Authors) == 0 && len(f.Tags) == 0 && f.Since == nil && f.Until == nil
+}
+
+// Every event from the relays is saved through here, to keep the indexes next
+// to the eventstore up to date.
+// 1. Expired events, and events their author deleted, are not stored again
+// 2. A deletion removes the events it refers to
+func (s eventService) save(ctx context.Context, e *nostr.Event) error {
+
+	if isExpired(e) {
+		return nil
+	}
+
+	deleted, err := s.isDeleted(ctx, e)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		return nil
+	}
+
+	// Stale versions of replaceable events are discarded, as are events that
+	// are already stored
+	if replaceableKey(e) != "" {
+		stored, err := s.replace(ctx, e)
+		if err != nil || !stored {
+			return err
+		}
+	} else {
+		wdb := eventstore.RelayWrapper{Store: s.db}
+
+		// Nothing changes when an event is fetched again
+		stored, err := wdb.QuerySync(ctx, nostr.Filter{IDs: []string{e.ID}})
+		if err != nil || len(stored) != 0 {
+			return err
+		}
+
+		err = wdb.Publish(ctx, *e)
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.Kind == nostr.KindDeletion {
+		err = s.applyDeletion(ctx, e)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Rendered articles are outdated by a newer version or a new highlight
+	s.mem.forgetEvent(e)
+	if e.Kind == 9802 {
+		for _, t := range e.Tags {
+			if t.Key() == "a" {
+				s.mem.Invalidate(t.Value())
+			}
+		}
+	}
+
+	s.touch(e)
+
+	err = s.indexReferences(e)
+	if err != nil {
+		return err
+	}
+
+	return s.indexExpiration(e)
+}
+
+// Request the filter from every relay. Relays can have different versions of a
+// replaceable event, only the newest one is returned.
 func (s *eventService) queryRelays(ctx context.Context, filter nostr.Filter) (ev []*nostr.Event) {
 
 	var m sync.Map
 	var wg sync.WaitGroup
+
+	// Relays that served each event, to know which ones have a stale version
+	var mu sync.Mutex
+	servedBy := map[string][]string{}
+
 	for _, url := range s.relays {
 
 		wg.Add(1)
 		go func(wg *sync.WaitGroup, url string) {
 			defer wg.Done()
 
+			// Unreachable relays are skipped, the others might still have the event
 			r, err := nostr.RelayConnect(ctx, url)
 			if err != nil {
-				panic(err)
+				return
 			}
+			defer r.Close()
 
 			events, err := r.QuerySync(ctx, filter)
 			if err != nil {
@@ -221,7 +680,12 @@ func (s *eventService) queryRelays(ctx context.Context, filter nostr.Filter) (ev
 			}
 
 			for _, e := range events {
-				m.Store(e.ID, e)
+				if s.accept(url, e, nostr.Filters{filter}) {
+					m.Store(e.ID, e)
+					mu.Lock()
+					servedBy[e.ID] = append(servedBy[e.ID], url)
+					mu.Unlock()
+				}
 			}
 
 		}(&wg, url)
@@ -233,5 +697,26 @@ func (s *eventService) queryRelays(ctx context.Context, filter nostr.Filter) (ev
 		return true
 	})
 
+	ev, stale := newestVersions(ev)
+
+	if s.republishStale && len(stale) != 0 {
+		newest := map[string]*nostr.Event{}
+		for _, e := range ev {
+			if key := replaceableKey(e); key != "" {
+				newest[key] = e
+			}
+		}
+		for _, e := range stale {
+			latest := newest[replaceableKey(e)]
+			relays := []string{}
+			for _, url := range servedBy[e.ID] {
+				if !slices.Contains(servedBy[latest.ID], url) {
+					relays = append(relays, url)
+				}
+			}
+			s.republish(latest, relays)
+		}
+	}
+
 	return ev
 }
@@ -0,0 +1,293 @@
+package notezero
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dextryz/notezero/memory"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func newTestService(t *testing.T) eventService {
+	t.Helper()
+	return NewEventService(memory.NewEvents(), memory.New(), nil)
+}
+
+func signed(t *testing.T, sk string, e nostr.Event) *nostr.Event {
+	t.Helper()
+	if e.CreatedAt == 0 {
+		e.CreatedAt = nostr.Now()
+	}
+	err := e.Sign(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &e
+}
+
+func TestRemainder(t *testing.T) {
+
+	a := &nostr.Event{ID: "id-a", PubKey: "pk-a", Kind: 0}
+	b := &nostr.Event{ID: "id-b", PubKey: "pk-b", Kind: 30023, Tags: nostr.Tags{{"d", "b"}}}
+
+	tests := []struct {
+		name   string
+		filter nostr.Filter
+		cached []*nostr.Event
+		want   *nostr.Filter
+	}{
+		{
+			name:   "ids all cached",
+			filter: nostr.Filter{IDs: []string{"id-a"}},
+			cached: []*nostr.Event{a},
+		},
+		{
+			name:   "ids partly cached",
+			filter: nostr.Filter{IDs: []string{"id-a", "id-c"}},
+			cached: []*nostr.Event{a},
+			want:   &nostr.Filter{IDs: []string{"id-c"}},
+		},
+		{
+			name:   "profiles partly cached",
+			filter: nostr.Filter{Kinds: []int{0}, Authors: []string{"pk-a", "pk-c"}},
+			cached: []*nostr.Event{a},
+			want:   &nostr.Filter{Kinds: []int{0}, Authors: []string{"pk-c"}},
+		},
+		{
+			name:   "addresses partly cached",
+			filter: nostr.Filter{Kinds: []int{30023}, Authors: []string{"pk-b"}, Tags: nostr.TagMap{"d": []string{"b", "c"}}},
+			cached: []*nostr.Event{b},
+			want:   &nostr.Filter{Kinds: []int{30023}, Authors: []string{"pk-b"}, Tags: nostr.TagMap{"d": []string{"c"}}},
+		},
+		{
+			name:   "list with something cached",
+			filter: nostr.Filter{Kinds: []int{1}, Authors: []string{"pk-a", "pk-c"}},
+			cached: []*nostr.Event{{ID: "id-n", PubKey: "pk-a", Kind: 1}},
+		},
+		{
+			name:   "list with nothing cached",
+			filter: nostr.Filter{Kinds: []int{1}, Authors: []string{"pk-a"}},
+			want:   &nostr.Filter{Kinds: []int{1}, Authors: []string{"pk-a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := remainder(tt.filter, tt.cached)
+			if tt.want == nil {
+				if ok {
+					t.Fatalf("requested %v, want nothing", got)
+				}
+				return
+			}
+			if !ok || !nostr.FilterEqual(got, *tt.want) {
+				t.Fatalf("got %v %v, want %v", got, ok, *tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchEventsPartlyCached(t *testing.T) {
+
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	cachedProfile := signed(t, sk, nostr.Event{Kind: 0, Content: "{}"})
+	cachedNote := signed(t, sk, nostr.Event{Kind: 1, Content: "cached"})
+
+	otherSk := nostr.GeneratePrivateKey()
+	remoteProfile := signed(t, otherSk, nostr.Event{Kind: 0, Content: "{}"})
+	remoteNote := signed(t, otherSk, nostr.Event{Kind: 1, Content: "remote"})
+
+	relay := newTestRelay(t, remoteProfile, remoteNote)
+
+	s := newTestService(t)
+	s.relays = []string{relay.URL}
+
+	for _, e := range []*nostr.Event{cachedProfile, cachedNote} {
+		err := s.db.SaveEvent(ctx, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := s.FetchEvents(ctx, nostr.Filters{
+		{Kinds: []int{0}, Authors: []string{cachedProfile.PubKey, remoteProfile.PubKey}},
+		{IDs: []string{cachedNote.ID, remoteNote.ID}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, e := range events {
+		got = append(got, e.ID)
+	}
+	want := []string{cachedProfile.ID, cachedNote.ID, remoteProfile.ID, remoteNote.ID}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// Only what the store did not have was requested
+	requests := relay.requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	for _, f := range requests[0] {
+		if slices.Contains(f.Authors, cachedProfile.PubKey) || slices.Contains(f.IDs, cachedNote.ID) {
+			t.Errorf("requested cached events: %v", f)
+		}
+	}
+}
+
+// The articles of an author, which wikilinks resolve against, are requested
+// even when the store has some, but not again within the refresh interval.
+func TestFetchEventsRefreshesLists(t *testing.T) {
+
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	cached := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "cached"}}})
+	remote := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "remote"}}, CreatedAt: cached.CreatedAt - 1})
+
+	relay := newTestRelay(t, remote)
+
+	s := newTestService(t)
+	s.relays = []string{relay.URL}
+
+	err := s.db.SaveEvent(ctx, cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := nostr.Filters{{Kinds: []int{nostr.KindArticle}, Authors: []string{cached.PubKey}}}
+
+	for i := 0; i < 2; i++ {
+		events, err := s.FetchEvents(ctx, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 {
+			t.Fatalf("fetch %d: got %d events, want 2", i, len(events))
+		}
+	}
+
+	if n := len(relay.requests()); n != 1 {
+		t.Fatalf("got %d requests, want 1", n)
+	}
+}
+
+// Wait for the background refreshes of the service to finish.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRequestEventChecksDeletions(t *testing.T) {
+
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	article := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "gone"}}})
+	deletion := signed(t, sk, nostr.Event{Kind: nostr.KindDeletion, Tags: nostr.Tags{{"e", article.ID}}, CreatedAt: article.CreatedAt + 1})
+
+	relay := newTestRelay(t, deletion)
+
+	s := newTestService(t).WithMemoryCache(NewMemoryCache(1))
+	s.relays = []string{relay.URL}
+
+	err := s.save(ctx, article)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	naddr, err := nip19.EncodeEntity(article.PubKey, article.Kind, "gone", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Served from the store, while the deletion is requested in the background
+	e, err := s.RequestEvent(ctx, naddr)
+	if err != nil || e.ID != article.ID {
+		t.Fatalf("got %v %v, want the cached article", e, err)
+	}
+
+	waitFor(t, func() bool {
+		deleted, _ := s.isDeleted(ctx, article)
+		return deleted
+	})
+
+	// Gone from the store and from memory, so the relays are asked, which only
+	// have the deletion
+	_, err = s.RequestEvent(ctx, naddr)
+	if err == nil {
+		t.Fatal("deleted article is still served")
+	}
+}
+
+func TestRefreshIsThrottled(t *testing.T) {
+
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+
+	article := signed(t, sk, nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "a"}, {"t", "go"}}})
+	relay := newTestRelay(t, article)
+
+	s := newTestService(t)
+	s.relays = []string{relay.URL}
+
+	// The first view waits for the relays
+	events, err := s.TagArticles(ctx, "go")
+	if err != nil || len(events) != 1 {
+		t.Fatalf("got %v %v, want the article", events, err)
+	}
+
+	// Views within the refresh interval are served from the store only
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.TagArticles(ctx, "go")
+		}()
+	}
+	wg.Wait()
+	time.Sleep(100 * time.Millisecond)
+
+	if n := len(relay.requests()); n != 1 {
+		t.Fatalf("got %d requests, want 1", n)
+	}
+
+	// Once the interval passed, one refresh is shared by all views
+	err = setJSON(s.cache, cacheKey(nsTags, "go"), nostr.Now()-nostr.Timestamp(refreshInterval.Seconds()), syncTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.TagArticles(ctx, "go")
+		}()
+	}
+	wg.Wait()
+
+	waitFor(t, func() bool { return len(relay.requests()) >= 2 })
+	time.Sleep(100 * time.Millisecond)
+
+	if n := len(relay.requests()); n != 2 {
+		t.Fatalf("got %d requests, want 2", n)
+	}
+}
@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const ddl = `CREATE TABLE IF NOT EXISTS cache (
+	key TEXT PRIMARY KEY,
+	value BLOB NOT NULL,
+	expires_at INTEGER NOT NULL DEFAULT 0
+)`
+
+// Key-value store next to the eventstore, in a table of the same SQLite
+// database. Expired rows are ignored and removed when they are read.
+type Cache struct {
+	*sqlx.DB
+}
+
+func New(db *sqlx.DB) (*Cache, error) {
+
+	_, err := db.Exec(ddl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		DB: db,
+	}, nil
+}
+
+// The value of the key, false if it does not exist or expired.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+
+	var value []byte
+	var expiresAt int64
+
+	err := c.QueryRow(`SELECT value, expires_at FROM cache WHERE key = ?`, key).Scan(&value, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if expiresAt != 0 && expiresAt <= time.Now().Unix() {
+		return nil, false, c.Delete(key)
+	}
+
+	return value, true, nil
+}
+
+// Set the value of the key, which expires after the ttl or never when the
+// ttl is zero.
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
+
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).Unix()
+	}
+
+	if value == nil {
+		value = []byte{}
+	}
+
+	_, err := c.Exec(`INSERT INTO cache (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, value, expiresAt)
+
+	return err
+}
+
+func (c *Cache) Delete(key string) error {
+	_, err := c.Exec(`DELETE FROM cache WHERE key = ?`, key)
+	return err
+}
+
+// All keys that start with the prefix, in order.
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	err := c.Select(&keys, `SELECT key FROM cache
+		WHERE key >= ? AND substr(key, 1, length(?)) = ? AND (expires_at = 0 OR expires_at > ?)
+		ORDER BY key`,
+		prefix, prefix, prefix, time.Now().Unix())
+	return keys, err
+}
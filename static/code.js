@@ -0,0 +1,15 @@
+// Copy the code of a highlighted code block, without the line numbers.
+document.addEventListener("click", (event) => {
+    const button = event.target.closest(".copy-code");
+    if (!button) {
+        return;
+    }
+
+    const code = button.parentElement.querySelector("code").cloneNode(true);
+    code.querySelectorAll(".ln").forEach((n) => n.remove());
+
+    navigator.clipboard.writeText(code.textContent).then(() => {
+        button.textContent = "Copied";
+        setTimeout(() => (button.textContent = "Copy"), 2000);
+    });
+});
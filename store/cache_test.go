@@ -0,0 +1,72 @@
+package store
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+
+	for _, backend := range []string{Badger, SQLite, LMDB, Memory} {
+		t.Run(backend, func(t *testing.T) {
+			t.Parallel()
+
+			if _, ok := backends[backend]; !ok {
+				t.Skip("not built in")
+			}
+
+			st, err := Open(backend, filepath.Join(t.TempDir(), "store"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.Close()
+
+			c := st.Cache
+
+			for key, ttl := range map[string]time.Duration{
+				"tags:go":     0,
+				"tags:nostr":  time.Second,
+				"nip05:alice": 0,
+			} {
+				err := c.Set(key, []byte(key), ttl)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			val, found, err := c.Get("tags:nostr")
+			if err != nil || !found || string(val) != "tags:nostr" {
+				t.Fatalf("got %q %v %v, want the value", val, found, err)
+			}
+
+			keys, err := c.Keys("tags:")
+			if err != nil || !slices.Equal(keys, []string{"tags:go", "tags:nostr"}) {
+				t.Fatalf("got keys %v %v", keys, err)
+			}
+
+			// Expiry has a resolution of a second in some backends
+			time.Sleep(2100 * time.Millisecond)
+
+			_, found, err = c.Get("tags:nostr")
+			if err != nil || found {
+				t.Fatalf("expired key found: %v", err)
+			}
+
+			keys, err = c.Keys("tags:")
+			if err != nil || !slices.Equal(keys, []string{"tags:go"}) {
+				t.Fatalf("got keys %v %v, want the key without a ttl", keys, err)
+			}
+
+			err = c.Delete("tags:go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, found, err = c.Get("tags:go")
+			if err != nil || found {
+				t.Fatalf("deleted key found: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Events per query, within the limit of every backend.
+const exportPage = 500
+
+// Write the events that match the filter as JSON lines, newest first, and
+// return how many. The filter limit caps the total, not a page.
+//
+// Backends return a limited number of events per query, so the store is read
+// in pages that end where the previous one did. Events at the timestamp of a
+// page boundary are read again and skipped. Exporting fails if a page does not
+// fit the events of a single second.
+func Export(ctx context.Context, db eventstore.Store, w io.Writer, filter nostr.Filter) (int, error) {
+
+	wdb := eventstore.RelayWrapper{Store: db}
+	enc := json.NewEncoder(w)
+
+	total := filter.Limit
+	count := 0
+	seen := map[string]bool{}
+
+	page := filter
+	page.Limit = exportPage
+
+	for {
+		events, err := wdb.QuerySync(ctx, page)
+		if err != nil {
+			return count, err
+		}
+
+		for _, e := range events {
+			if seen[e.ID] {
+				continue
+			}
+			err := enc.Encode(e)
+			if err != nil {
+				return count, err
+			}
+			count++
+			if total > 0 && count == total {
+				return count, nil
+			}
+		}
+
+		if len(events) < exportPage {
+			return count, nil
+		}
+
+		// Continue from the oldest timestamp of the page, remembering which
+		// events at that timestamp were written
+		oldest := events[len(events)-1].CreatedAt
+		if page.Until != nil && *page.Until == oldest {
+			// Filters cannot page within a second, and skipping past it would
+			// leave out the rest of its events
+			return count, fmt.Errorf("more than %d events at %d, export them with a narrower filter", exportPage, oldest)
+		}
+
+		seen = map[string]bool{}
+		for _, e := range events {
+			if e.CreatedAt == oldest {
+				seen[e.ID] = true
+			}
+		}
+
+		page.Until = &oldest
+	}
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	nz "github.com/dextryz/notezero"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestExportImport(t *testing.T) {
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	sk := nostr.GeneratePrivateKey()
+
+	src, err := Open(Badger, filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	// More than a page, with a page boundary within the notes of one second
+	notes := signedNotes(t, sk, 0, 700)
+
+	sign := func(e nostr.Event) *nostr.Event {
+		err := e.Sign(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &e
+	}
+
+	for i := 0; i < 300; i++ {
+		notes = append(notes, sign(nostr.Event{Kind: nostr.KindTextNote, Content: strconv.Itoa(i), CreatedAt: 1400}))
+	}
+
+	oldProfile := sign(nostr.Event{Kind: nostr.KindProfileMetadata, Content: `{"name":"old"}`, CreatedAt: 2000})
+	newProfile := sign(nostr.Event{Kind: nostr.KindProfileMetadata, Content: `{"name":"new"}`, CreatedAt: 2001})
+	article := sign(nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "gone"}}, Content: "gone", CreatedAt: 2002})
+	deletion := sign(nostr.Event{Kind: nostr.KindDeletion, Tags: nostr.Tags{{"e", article.ID}}, CreatedAt: 2003})
+	tampered := sign(nostr.Event{Kind: nostr.KindTextNote, Content: "signed", CreatedAt: 2004})
+	tampered.Content = "tampered"
+
+	// Written as is, like a store filled by an older version
+	save(t, src, append(notes, oldProfile, newProfile, article, deletion, tampered))
+
+	var buf bytes.Buffer
+	count, err := Export(ctx, src.Events, &buf, nostr.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(notes) + 5; count != want {
+		t.Fatalf("exported %d events, want %d", count, want)
+	}
+
+	dst, err := Open(Badger, filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	s := nz.NewEventService(dst.Events, dst.Cache, nil)
+
+	rejected := []string{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e nostr.Event
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Import(ctx, &e)
+		if errors.Is(err, nz.ErrRejected) {
+			rejected = append(rejected, e.ID)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(rejected) != 1 || rejected[0] != tampered.ID {
+		t.Fatalf("rejected %v, want only the tampered event", rejected)
+	}
+
+	// Every note, the newest profile and the deletion, without what it deleted
+	for _, e := range notes {
+		if len(query(t, dst, nostr.Filter{IDs: []string{e.ID}})) != 1 {
+			t.Fatalf("note %s is missing", e.ID)
+		}
+	}
+	profiles := query(t, dst, nostr.Filter{Kinds: []int{nostr.KindProfileMetadata}})
+	if len(profiles) != 1 || profiles[newProfile.ID] == "" {
+		t.Fatalf("got profiles %v, want only the newest", profiles)
+	}
+	if len(query(t, dst, nostr.Filter{IDs: []string{deletion.ID}})) != 1 {
+		t.Fatal("deletion is missing")
+	}
+	if len(query(t, dst, nostr.Filter{IDs: []string{article.ID}})) != 0 {
+		t.Fatal("deleted article was imported")
+	}
+}
+
+// Filters cannot page within a second, so the export fails instead of
+// leaving out events.
+func TestExportFullSecond(t *testing.T) {
+
+	st, err := Open(Badger, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	notes := []*nostr.Event{}
+	for i := 0; i < exportPage+1; i++ {
+		e := &nostr.Event{Kind: nostr.KindTextNote, Content: strconv.Itoa(i), CreatedAt: 1000}
+		err := e.Sign(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		notes = append(notes, e)
+	}
+	save(t, st, notes)
+
+	_, err = Export(context.Background(), st.Events, io.Discard, nostr.Filter{})
+	if err == nil {
+		t.Fatal("exported more events of one second than fit a page")
+	}
+}
@@ -0,0 +1,36 @@
+//go:build lmdb
+
+package store
+
+import (
+	"path/filepath"
+
+	"github.com/dextryz/notezero/lmdb"
+	eventstore_lmdb "github.com/fiatjaf/eventstore/lmdb"
+)
+
+func init() {
+	backends[LMDB] = openLMDB
+}
+
+// The cache is a separate environment inside the directory of the events.
+func openLMDB(path string) (*Store, error) {
+
+	db := &eventstore_lmdb.LMDBBackend{
+		Path: path,
+	}
+	err := db.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := lmdb.New(filepath.Join(path, "cache"))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	closeCache := func() { cache.Close() }
+
+	return &Store{Events: db, Cache: cache, closers: []func(){db.Close, closeCache}}, nil
+}
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	nz "github.com/dextryz/notezero"
+	"github.com/dextryz/notezero/badger"
+	"github.com/dextryz/notezero/memory"
+	"github.com/dextryz/notezero/sqlite"
+	badgerdb "github.com/dgraph-io/badger/v4"
+	"github.com/fiatjaf/eventstore"
+	eventstore_badger "github.com/fiatjaf/eventstore/badger"
+	eventstore_sqlite "github.com/fiatjaf/eventstore/sqlite3"
+)
+
+// Space given back by Compact, served on /debug/vars.
+var compactionStats = expvar.NewMap("compaction")
+
+// Backends of the eventstore.
+const (
+	Badger = "badger"
+	SQLite = "sqlite"
+	LMDB   = "lmdb"
+	Memory = "memory"
+)
+
+// Eventstore with the key-value cache of the same backend.
+type Store struct {
+	Events  eventstore.Store
+	Cache   nz.Cache
+	Backend string
+	Path    string
+	closers []func()
+	// Give the space of deleted events back to the filesystem
+	compact func() error
+	backup  func(w io.Writer) error
+}
+
+type opener func(path string) (*Store, error)
+
+var backends = map[string]opener{
+	Badger: openBadger,
+	SQLite: openSQLite,
+	Memory: openMemory,
+}
+
+// Where the backend keeps its data when no path is configured.
+var defaultPaths = map[string]string{
+	Badger: "nostr.db",
+	SQLite: "nostr.sqlite",
+	LMDB:   "nostr.lmdb",
+}
+
+// Names of the backends built into the binary, sorted.
+func Backends() []string {
+	names := []string{}
+	for name := range backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open the backend at the path, or at its default path when empty.
+func Open(backend, path string) (*Store, error) {
+
+	open, ok := backends[backend]
+	if !ok {
+		if backend == LMDB {
+			return nil, fmt.Errorf("store: lmdb is not built in, build with -tags lmdb")
+		}
+		return nil, fmt.Errorf("store: unknown backend %q, use one of %v", backend, Backends())
+	}
+
+	if path == "" {
+		path = defaultPaths[backend]
+	}
+
+	s, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to open %s at %q: %w", backend, path, err)
+	}
+	s.Backend = backend
+	s.Path = path
+
+	return s, nil
+}
+
+func (s *Store) Close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+}
+
+// Compact the store and return how many bytes it gave back to the
+// filesystem. Backends that reuse the space of deleted events themselves,
+// like LMDB, and the in-memory one have nothing to compact.
+func (s *Store) Compact() (int64, error) {
+
+	if s.compact == nil {
+		return 0, nil
+	}
+
+	before, err := diskSize(s.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.compact()
+	if err != nil {
+		return 0, err
+	}
+
+	after, err := diskSize(s.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	reclaimed := max(before-after, 0)
+	compactionStats.Add("runs", 1)
+	compactionStats.Add("reclaimed_bytes", reclaimed)
+
+	return reclaimed, nil
+}
+
+// Write a backup of the whole store, which Restore loads again. Only badger
+// supports it, the events of other backends can be exported instead.
+func (s *Store) Backup(w io.Writer) error {
+	if s.backup == nil {
+		return fmt.Errorf("store: %s does not support backups, export the events instead", s.Backend)
+	}
+	return s.backup(w)
+}
+
+// Load a backup into a new badger store at the path, or at the default path
+// when empty. Events are keyed by serial numbers that the open store hands
+// out from memory, so a backup is never loaded into a store that is open or
+// already has data: the restored events would be overwritten by new ones.
+func Restore(backend, path string, r io.Reader) error {
+
+	if backend != Badger {
+		return fmt.Errorf("store: %s does not support backups, import the events instead", backend)
+	}
+
+	if path == "" {
+		path = defaultPaths[backend]
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(entries) != 0 {
+		return fmt.Errorf("store: %q is not empty, restore into a new path", path)
+	}
+
+	db, err := badgerdb.Open(badgerdb.DefaultOptions(path).WithLogger(nil))
+	if err != nil {
+		return err
+	}
+
+	err = db.Load(r, 256)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	return db.Close()
+}
+
+// Size of a file, or of all files in a directory.
+func diskSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func openBadger(path string) (*Store, error) {
+
+	db := &eventstore_badger.BadgerBackend{
+		Path: path,
+	}
+	err := db.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := badger.New(db.DB)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Rewrite value log files until none is worth it
+	compact := func() error {
+		for {
+			err := db.RunValueLogGC(0.5)
+			if errors.Is(err, badgerdb.ErrNoRewrite) || errors.Is(err, badgerdb.ErrRejected) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// A snapshot at a single point in time, so it is consistent while the
+	// server keeps writing. It includes the cache next to the events.
+	backup := func(w io.Writer) error {
+		_, err := db.Backup(w, 0)
+		return err
+	}
+
+	return &Store{
+		Events:  db,
+		Cache:   cache,
+		closers: []func(){db.Close},
+		compact: compact,
+		backup:  backup,
+	}, nil
+}
+
+// The cache is a table of the same database.
+func openSQLite(path string) (*Store, error) {
+
+	db := &eventstore_sqlite.SQLite3Backend{
+		DatabaseURL: path,
+	}
+	err := db.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := sqlite.New(db.DB)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	compact := func() error {
+		_, err := db.Exec("VACUUM")
+		return err
+	}
+
+	return &Store{Events: db, Cache: cache, closers: []func(){db.Close}, compact: compact}, nil
+}
+
+// Nothing is kept on disk, the path is ignored.
+func openMemory(string) (*Store, error) {
+
+	db := memory.NewEvents()
+
+	return &Store{Events: db, Cache: memory.New(), closers: []func(){db.Close}}, nil
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func signedNotes(t *testing.T, sk string, from, count int) []*nostr.Event {
+	t.Helper()
+	events := []*nostr.Event{}
+	for i := from; i < from+count; i++ {
+		e := &nostr.Event{
+			Kind:      nostr.KindTextNote,
+			Content:   "note " + string(rune('a'+i%26)),
+			CreatedAt: nostr.Timestamp(1000 + i),
+			Tags:      nostr.Tags{{"t", "test"}},
+		}
+		err := e.Sign(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func save(t *testing.T, st *Store, events []*nostr.Event) {
+	t.Helper()
+	for _, e := range events {
+		err := st.Events.SaveEvent(context.Background(), e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Events by id, and the ids of the events that match the filter.
+func query(t *testing.T, st *Store, filter nostr.Filter) map[string]string {
+	t.Helper()
+	wdb := eventstore.RelayWrapper{Store: st.Events}
+	events, err := wdb.QuerySync(context.Background(), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]string{}
+	for _, e := range events {
+		found[e.ID] = e.Content
+	}
+	return found
+}
+
+func TestBackupRestore(t *testing.T) {
+
+	dir := t.TempDir()
+	sk := nostr.GeneratePrivateKey()
+
+	st, err := Open(Badger, filepath.Join(dir, "live"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	backedUp := signedNotes(t, sk, 0, 20)
+	save(t, st, backedUp)
+
+	err = st.Cache.Set("refs:key", []byte("value"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var backup bytes.Buffer
+	err = st.Backup(&backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Written after the backup, so not part of it
+	save(t, st, signedNotes(t, sk, 20, 5))
+
+	// A store with data is never restored over
+	err = Restore(Badger, filepath.Join(dir, "live"), bytes.NewReader(backup.Bytes()))
+	if err == nil {
+		t.Fatal("restored over a store with data")
+	}
+
+	path := filepath.Join(dir, "restored")
+	err = Restore(Badger, path, bytes.NewReader(backup.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := Open(Badger, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	// New events must not take the serials of the restored ones
+	added := signedNotes(t, sk, 100, 5)
+	save(t, restored, added)
+
+	want := map[string]string{}
+	for _, e := range append(backedUp, added...) {
+		want[e.ID] = e.Content
+	}
+
+	tests := []struct {
+		name   string
+		filter nostr.Filter
+	}{
+		{"kind", nostr.Filter{Kinds: []int{nostr.KindTextNote}}},
+		{"author", nostr.Filter{Authors: []string{backedUp[0].PubKey}}},
+		{"tag", nostr.Filter{Tags: nostr.TagMap{"t": []string{"test"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := query(t, restored, tt.filter)
+			if len(got) != len(want) {
+				t.Fatalf("got %d events, want %d", len(got), len(want))
+			}
+			for id, content := range want {
+				if got[id] != content {
+					t.Errorf("event %s: got %q, want %q", id, got[id], content)
+				}
+			}
+		})
+	}
+
+	ids := []string{}
+	for id := range want {
+		ids = append(ids, id)
+	}
+	if got := query(t, restored, nostr.Filter{IDs: ids}); len(got) != len(want) {
+		t.Errorf("got %d events by id, want %d", len(got), len(want))
+	}
+
+	value, found, err := restored.Cache.Get("refs:key")
+	if err != nil || !found || string(value) != "value" {
+		t.Errorf("cache key not restored: %q %v %v", value, found, err)
+	}
+}